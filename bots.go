@@ -0,0 +1,126 @@
+package prmonitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bots describes which authors are bots, such as dependabot or
+// renovate, and how their pull requests should be displayed.
+type Bots struct {
+	// logins to treat as bots, in addition to "[bot]" accounts.
+	Logins []string
+
+	// "show" (the default) draws bot PRs like any other, "hide" drops
+	// them, and "group" draws a single summary row per repo.
+	Mode string
+
+	// optional color thresholds for bot PRs in "show" mode, so they
+	// don't turn red alongside PRs waiting on people. Missing fields
+	// fall back to Config.Customization.
+	Customization *Customization
+}
+
+// isBot reports whether author is a bot account.
+func (b Bots) isBot(author string) bool {
+	if strings.HasSuffix(author, "[bot]") {
+		return true
+	}
+	for _, l := range b.Logins {
+		if strings.EqualFold(l, author) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleBots marks SummarizedPullRequests whose Author is a bot, then
+// hides or groups them according to bots.Mode. Grouping has to see
+// every bot PR of a repo, so group rows are passed on once the input
+// is drained. A group row spans the repo's open bot PRs, so that an
+// old closed one doesn't make it look long overdue, and only spans
+// the closed ones when none are open.
+func HandleBots(in <-chan SummarizedPullRequest, bots Bots) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		var order []string
+		groups := map[string]*botGroup{}
+		for v := range in {
+			if !bots.isBot(v.Author) {
+				out <- v
+				continue
+			}
+			v.Bot = true
+			switch bots.Mode {
+			case "hide":
+			case "group":
+				key := fmt.Sprintf("%s/%s", v.Owner, v.Repo)
+				g, ok := groups[key]
+				if !ok {
+					order = append(order, key)
+					g = &botGroup{
+						row: SummarizedPullRequest{
+							Owner:     v.Owner,
+							Repo:      v.Repo,
+							Author:    "bots",
+							Bot:       true,
+							UpdatedAt: v.UpdatedAt,
+						},
+						spans: map[string]period{},
+					}
+					groups[key] = g
+				}
+				g.add(v)
+			default:
+				out <- v
+			}
+		}
+		for _, key := range order {
+			out <- groups[key].summary()
+		}
+		close(out)
+	}()
+	return out
+}
+
+// botGroup gathers the bot PRs of a repo into a single row.
+type botGroup struct {
+	row SummarizedPullRequest
+
+	// how long the PRs in each state were open for, from the first
+	// opened to the last closed.
+	spans map[string]period
+}
+
+// add counts v in the group.
+func (g *botGroup) add(v SummarizedPullRequest) {
+	g.row.Grouped++
+	if v.UpdatedAt.After(g.row.UpdatedAt) {
+		g.row.UpdatedAt = v.UpdatedAt
+	}
+	s, ok := g.spans[v.State]
+	if !ok {
+		s = period{v.OpenedAt, v.ClosedAt}
+	}
+	if v.OpenedAt.Before(s.from) {
+		s.from = v.OpenedAt
+	}
+	if v.ClosedAt.After(s.to) {
+		s.to = v.ClosedAt
+	}
+	g.spans[v.State] = s
+}
+
+// summary returns the group's row, spanning its open PRs if there
+// are any.
+func (g *botGroup) summary() SummarizedPullRequest {
+	row := g.row
+	row.Title = fmt.Sprintf("%d bot pull requests", row.Grouped)
+	row.State = "closed"
+	if _, ok := g.spans["open"]; ok {
+		row.State = "open"
+	}
+	s := g.spans[row.State]
+	row.OpenedAt, row.ClosedAt = s.from, s.to
+	return row
+}
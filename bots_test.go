@@ -0,0 +1,108 @@
+package prmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+func handleBots(prs []SummarizedPullRequest, bots Bots) []SummarizedPullRequest {
+	in := make(chan SummarizedPullRequest)
+	out := HandleBots(in, bots)
+	go func() {
+		for _, pr := range prs {
+			in <- pr
+		}
+		close(in)
+	}()
+	var handled []SummarizedPullRequest
+	for pr := range out {
+		handled = append(handled, pr)
+	}
+	return handled
+}
+
+func botPRs(now time.Time) []SummarizedPullRequest {
+	return []SummarizedPullRequest{
+		{Owner: "brentdrich", Repo: "prmonitor", Number: 1, Author: "brentdrich", OpenedAt: now.Add(-time.Hour), ClosedAt: now, State: "open"},
+		{Owner: "brentdrich", Repo: "prmonitor", Number: 2, Author: "dependabot[bot]", OpenedAt: now.Add(-50 * time.Hour), ClosedAt: now.Add(-40 * time.Hour), State: "closed"},
+		{Owner: "brentdrich", Repo: "prmonitor", Number: 3, Author: "renovate-bot", OpenedAt: now.Add(-10 * time.Hour), ClosedAt: now, State: "open"},
+		{Owner: "brentdrich", Repo: "other", Number: 4, Author: "dependabot[bot]", OpenedAt: now.Add(-5 * time.Hour), ClosedAt: now, State: "open"},
+	}
+}
+
+func TestHandleBotsHide(t *testing.T) {
+	prs := handleBots(botPRs(time.Now()), Bots{Mode: "hide", Logins: []string{"renovate-bot"}})
+	if len(prs) != 1 || prs[0].Number != 1 {
+		t.Logf("ERROR: expected only the human PR, but got %+v", prs)
+		t.Fail()
+	}
+}
+
+func TestHandleBotsShow(t *testing.T) {
+	prs := handleBots(botPRs(time.Now()), Bots{})
+	if len(prs) != 4 {
+		t.Logf("ERROR: expected %d PRs, but got %d", 4, len(prs))
+		t.Fail()
+		return
+	}
+	if prs[0].Bot || !prs[1].Bot || prs[2].Bot || !prs[3].Bot {
+		t.Logf("ERROR: expected only [bot] accounts to be marked, but got %+v", prs)
+		t.Fail()
+	}
+}
+
+func TestHandleBotsGroup(t *testing.T) {
+	now := time.Now()
+	prs := handleBots(botPRs(now), Bots{Mode: "group", Logins: []string{"renovate-bot"}})
+	if len(prs) != 3 {
+		t.Logf("ERROR: expected one human PR and two group rows, but got %+v", prs)
+		t.Fail()
+		return
+	}
+
+	g := prs[1]
+	if g.Repo != "prmonitor" || g.Grouped != 2 || g.State != "open" || g.Title != "2 bot pull requests" {
+		t.Logf("ERROR: unexpected group row %+v", g)
+		t.Fail()
+	}
+	if !g.OpenedAt.Equal(now.Add(-10*time.Hour)) || !g.ClosedAt.Equal(now) {
+		t.Logf("ERROR: expected group row to span its open PRs, but got %s - %s", g.OpenedAt, g.ClosedAt)
+		t.Fail()
+	}
+	if prs[2].Repo != "other" || prs[2].Grouped != 1 {
+		t.Logf("ERROR: expected a group row per repo, but got %+v", prs[2])
+		t.Fail()
+	}
+}
+
+func TestHandleBotsGroupMixed(t *testing.T) {
+	now := time.Now()
+	prs := handleBots([]SummarizedPullRequest{
+		{Owner: "brentdrich", Repo: "prmonitor", Number: 1, Author: "dependabot[bot]", OpenedAt: now.Add(-time.Hour), ClosedAt: now, State: "open"},
+		{Owner: "brentdrich", Repo: "prmonitor", Number: 2, Author: "dependabot[bot]", OpenedAt: now.Add(-200 * time.Hour), ClosedAt: now.Add(-192 * time.Hour), State: "closed"},
+		{Owner: "brentdrich", Repo: "other", Number: 3, Author: "dependabot[bot]", OpenedAt: now.Add(-100 * time.Hour), ClosedAt: now.Add(-90 * time.Hour), State: "closed"},
+		{Owner: "brentdrich", Repo: "other", Number: 4, Author: "dependabot[bot]", OpenedAt: now.Add(-80 * time.Hour), ClosedAt: now.Add(-70 * time.Hour), State: "closed"},
+	}, Bots{Mode: "group"})
+	if len(prs) != 2 {
+		t.Logf("ERROR: expected two group rows, but got %+v", prs)
+		t.FailNow()
+	}
+
+	// an old closed PR mustn't age the row of a freshly opened one
+	g := prs[0]
+	if g.State != "open" || g.Grouped != 2 || !g.OpenedAt.Equal(now.Add(-time.Hour)) || !g.ClosedAt.Equal(now) {
+		t.Logf("ERROR: expected the row to span the open PR, but got %s %s - %s", g.State, g.OpenedAt, g.ClosedAt)
+		t.Fail()
+	}
+	if tier := getTier(Config{Customization: GetCustomizations()}, now.Sub(g.OpenedAt).Hours(), g.State); tier != "passive" {
+		t.Logf("ERROR: expected the row to be passive, but got %s", tier)
+		t.Fail()
+	}
+
+	// with nothing open, the row spans the closed PRs
+	g = prs[1]
+	if g.State != "closed" || !g.OpenedAt.Equal(now.Add(-100*time.Hour)) || !g.ClosedAt.Equal(now.Add(-70*time.Hour)) {
+		t.Logf("ERROR: expected the row to span the closed PRs, but got %s %s - %s", g.State, g.OpenedAt, g.ClosedAt)
+		t.Fail()
+	}
+}
@@ -0,0 +1,261 @@
+package prmonitor
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CachedResponse is a response body stored along with the validators
+// needed to make a conditional request for it later.
+type CachedResponse struct {
+	ETag         string
+	LastModified string
+	Header       http.Header
+	Body         []byte
+}
+
+// Cache stores responses for a CachingTransport, keyed by request.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, r *CachedResponse)
+}
+
+// DefaultMemoryCacheSize is a reasonable limit on the size of the
+// response bodies a MemoryCache holds, in bytes.
+const DefaultMemoryCacheSize = 64 << 20
+
+// MemoryCache is a Cache that lives as long as the process. Once its
+// responses add up to more than its limit, the least recently used
+// ones are dropped.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries *lru
+}
+
+// NewMemoryCache creates an empty in-memory cache holding up to
+// maxBytes of response bodies.
+func NewMemoryCache(maxBytes int) *MemoryCache {
+	return &MemoryCache{entries: newLRU(maxBytes)}
+}
+
+// Get returns the cached response for key, if any.
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries.get(key)
+	if !ok {
+		return nil, false
+	}
+	return r.(*CachedResponse), true
+}
+
+// Set stores a response under key.
+func (c *MemoryCache) Set(key string, r *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.add(key, r, len(key)+len(r.Body))
+}
+
+// lru is a map that drops its least recently used entries once their
+// sizes add up to more than max. It isn't safe for concurrent use.
+type lru struct {
+	max, size int
+	order     *list.List
+	items     map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+	size  int
+}
+
+func newLRU(max int) *lru {
+	return &lru{max: max, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// get returns the value stored under key, if any, and marks it as
+// recently used.
+func (l *lru) get(key string) (interface{}, bool) {
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// add stores value under key, then drops old entries until the rest
+// fit. A value bigger than max isn't stored at all.
+func (l *lru) add(key string, value interface{}, size int) {
+	if e, ok := l.items[key]; ok {
+		l.remove(e)
+	}
+	if size > l.max {
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key, value, size})
+	l.size += size
+	for l.size > l.max {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *lru) remove(e *list.Element) {
+	entry := l.order.Remove(e).(*lruEntry)
+	delete(l.items, entry.key)
+	l.size -= entry.size
+}
+
+// DiskCache is a Cache that stores one file per response in a
+// directory, so cached responses survive restarts.
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache creates a cache in dir, creating the directory if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the cached response for key, if any. Unreadable entries
+// are treated as missing.
+func (c *DiskCache) Get(key string) (*CachedResponse, bool) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var r CachedResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, false
+	}
+	return &r, true
+}
+
+// Set stores a response under key. Failures only cost a cache miss,
+// so they are ignored.
+func (c *DiskCache) Set(key string, r *CachedResponse) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	// write to a file of our own and rename it into place, so
+	// concurrent writers of the same key never interleave
+	f, err := ioutil.TempFile(c.Dir, "tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// CachingTransport is an http.RoundTripper that remembers the ETag and
+// Last-Modified validators of GET responses and sends them back as
+// If-None-Match and If-Modified-Since. When github answers with 304 Not
+// Modified, which doesn't count against the rate limit, the cached body
+// is returned instead.
+type CachingTransport struct {
+	Transport http.RoundTripper
+	Cache     Cache
+}
+
+// NewCachingTransport wraps transport (or http.DefaultTransport if nil)
+// with a cache.
+func NewCachingTransport(transport http.RoundTripper, cache Cache) *CachingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &CachingTransport{Transport: transport, Cache: cache}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return t.Transport.RoundTrip(req)
+	}
+
+	// the same url can be requested with different media types
+	key := req.Header.Get("Accept") + " " + req.URL.String()
+	cached, ok := t.Cache.Get(key)
+	if ok {
+		// a RoundTripper must not modify the request it was given
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header))
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		if cached.ETag != "" {
+			r.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			r.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+		req = r
+	}
+
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		header := make(http.Header, len(cached.Header))
+		for k, v := range cached.Header {
+			header[k] = v
+		}
+		// keep the fresh rate limit headers from the 304
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		header.Set("X-From-Cache", "1")
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header = header
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+		return resp, nil
+	}
+
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || modified != "") {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.Cache.Set(key, &CachedResponse{
+			ETag:         etag,
+			LastModified: modified,
+			Header:       resp.Header,
+			Body:         body,
+		})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
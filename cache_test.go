@@ -0,0 +1,151 @@
+package prmonitor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// etagServer serves a fixed body and honors If-None-Match, counting
+// how many full responses it had to send.
+func etagServer(full *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		*full++
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("[]"))
+	}))
+}
+
+func testCachingTransport(t *testing.T, cache Cache) {
+	full := 0
+	s := etagServer(&full)
+	defer s.Close()
+
+	c := &http.Client{Transport: NewCachingTransport(nil, cache)}
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(s.URL + "/repos/brentdrich/prmonitor/pulls")
+		if err != nil {
+			t.Logf("ERROR: request failed: %s", err)
+			t.Fail()
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 || string(body) != "[]" {
+			t.Logf("ERROR: expected cached body '[]' with code 200, but got '%s' with code %d", body, resp.StatusCode)
+			t.Fail()
+		}
+		if i > 0 && resp.Header.Get("X-From-Cache") != "1" {
+			t.Logf("ERROR: expected response %d to come from the cache", i)
+			t.Fail()
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") != "4999" {
+			t.Logf("ERROR: expected rate limit headers to be kept")
+			t.Fail()
+		}
+	}
+
+	if full != 1 {
+		t.Logf("ERROR: expected %d full response, but got %d", 1, full)
+		t.Fail()
+	}
+}
+
+func TestCachingTransportMemory(t *testing.T) {
+	testCachingTransport(t, NewMemoryCache(DefaultMemoryCacheSize))
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	c := NewMemoryCache(25)
+	c.Set("a", &CachedResponse{Body: make([]byte, 9)})
+	c.Set("b", &CachedResponse{Body: make([]byte, 9)})
+	c.Get("a")
+	c.Set("c", &CachedResponse{Body: make([]byte, 9)})
+	c.Set("d", &CachedResponse{Body: make([]byte, 100)})
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		if _, ok := c.Get(key); ok != expected {
+			t.Logf("ERROR: %s: expected cached %t, but got %t", key, expected, ok)
+			t.Fail()
+		}
+	}
+}
+
+func TestCachingTransportDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prmonitor")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		panic(err)
+	}
+	testCachingTransport(t, cache)
+
+	// a new cache in the same directory should reuse stored responses
+	full := 0
+	s := etagServer(&full)
+	defer s.Close()
+	for i := 0; i < 2; i++ {
+		reopened, _ := NewDiskCache(dir)
+		c := &http.Client{Transport: NewCachingTransport(nil, reopened)}
+		resp, err := c.Get(s.URL + "/repos/brentdrich/prmonitor/pulls")
+		if err != nil {
+			t.Logf("ERROR: request failed: %s", err)
+			t.Fail()
+			return
+		}
+		resp.Body.Close()
+	}
+	if full != 1 {
+		t.Logf("ERROR: expected %d full response across restarts, but got %d", 1, full)
+		t.Fail()
+	}
+}
+
+func TestDiskCacheConcurrentSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prmonitor")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		panic(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cache.Set("key", &CachedResponse{ETag: "etag", Body: bytes.Repeat([]byte{byte('a' + i)}, 100000)})
+		}(i)
+	}
+	wg.Wait()
+
+	r, ok := cache.Get("key")
+	if !ok || len(r.Body) != 100000 || !bytes.Equal(r.Body, bytes.Repeat(r.Body[:1], 100000)) {
+		t.Logf("ERROR: expected one writer's complete response to be cached")
+		t.Fail()
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+	if len(files) != 1 {
+		t.Logf("ERROR: expected only the cached response to be left, but got %d files", len(files))
+		t.Fail()
+	}
+}
@@ -0,0 +1,339 @@
+package prmonitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calendar describes when a team works, so pull requests only age
+// during working hours rather than over nights, weekends and holidays.
+type Calendar struct {
+	// IANA time zone the working hours are in, e.g.
+	// "America/Los_Angeles". Defaults to UTC.
+	TimeZone string
+
+	// working days, as three letter abbreviations such as "mon".
+	// Defaults to monday to friday.
+	WorkDays []string
+
+	// the hours of the day work starts and ends. Default to 9 and 17.
+	StartHour int
+	EndHour   int
+
+	// optional path to an iCal file of holidays. Every day covered by
+	// an event in it is treated as a day off.
+	Holidays string
+
+	once     sync.Once
+	err      error
+	location *time.Location
+	holidays map[string]bool
+}
+
+// Load looks up the time zone, reads the holiday file and checks the
+// working hours of c. It is called the first time the calendar is
+// used, and only does the work once, so calling it up front just
+// reports problems earlier.
+func (c *Calendar) Load() error {
+	c.once.Do(func() {
+		c.err = c.load()
+	})
+	return c.err
+}
+
+// load does the work of Load.
+func (c *Calendar) load() error {
+	startHour, endHour := c.hours()
+	if startHour < 0 || endHour > 24 || endHour <= startHour {
+		return fmt.Errorf("invalid working hours %d to %d, they must be from 0 to 24 and end after they start", startHour, endHour)
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return err
+	}
+	c.location = loc
+	c.holidays = map[string]bool{}
+	if c.Holidays == "" {
+		return nil
+	}
+	f, err := os.Open(c.Holidays)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	days, err := ParseICal(f)
+	if err != nil {
+		return err
+	}
+	for _, d := range days {
+		c.holidays[d.Format("2006-01-02")] = true
+	}
+	return nil
+}
+
+// ParseICal returns the days covered by the events in an iCal file.
+// Events without an end cover the day they start on. Events repeating
+// every year or every few weeks are expanded; other recurrence rules
+// are reported as errors rather than silently counted once.
+func ParseICal(r io.Reader) ([]time.Time, error) {
+	var days []time.Time
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		// long lines are folded onto continuation lines starting
+		// with whitespace
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	var start, end time.Time
+	var rule string
+	for _, line := range lines {
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		name, value := strings.ToUpper(line[:i]), line[i+1:]
+		if j := strings.Index(name, ";"); j >= 0 {
+			name = name[:j]
+		}
+		var err error
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			start, end, rule = time.Time{}, time.Time{}, ""
+		case name == "DTSTART":
+			start, err = parseICalDate(value)
+		case name == "DTEND":
+			end, err = parseICalDate(value)
+		case name == "RRULE":
+			rule = value
+		case name == "END" && value == "VEVENT":
+			if start.IsZero() {
+				continue
+			}
+			length := 1
+			if end.After(start) {
+				length = int(end.Sub(start).Hours()/24 + 0.5)
+			}
+			var starts []time.Time
+			starts, err = expandICalRule(start, rule)
+			for _, day := range starts {
+				for i := 0; i < length; i++ {
+					days = append(days, day.AddDate(0, 0, i))
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return days, nil
+}
+
+// icalHorizon is how many years recurring events are expanded for at
+// most.
+const icalHorizon = 100
+
+// expandICalRule returns the days an event starting on start recurs
+// on according to rule, an RRULE value. Only FREQ=YEARLY and
+// FREQ=WEEKLY with an optional INTERVAL, COUNT and UNTIL are
+// supported. An empty rule just gives start.
+func expandICalRule(start time.Time, rule string) ([]time.Time, error) {
+	if rule == "" {
+		return []time.Time{start}, nil
+	}
+	var freq string
+	interval, count := 1, 0
+	until := start.AddDate(icalHorizon, 0, 0)
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid recurrence rule %q", rule)
+		}
+		var err error
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			freq = strings.ToUpper(kv[1])
+		case "INTERVAL":
+			interval, err = strconv.Atoi(kv[1])
+			if err == nil && interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(kv[1])
+		case "UNTIL":
+			until, err = parseICalDate(kv[1])
+		case "WKST":
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule %q, %s isn't supported", rule, kv[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid recurrence rule %q: %s", rule, err)
+		}
+	}
+	if freq != "YEARLY" && freq != "WEEKLY" {
+		return nil, fmt.Errorf("unsupported recurrence rule %q, only yearly and weekly rules are supported", rule)
+	}
+	if horizon := start.AddDate(icalHorizon, 0, 0); until.After(horizon) {
+		until = horizon
+	}
+
+	var days []time.Time
+	for i := 0; count == 0 || len(days) < count; i++ {
+		d := start.AddDate(0, 0, 7*interval*i)
+		if freq == "YEARLY" {
+			d = start.AddDate(interval*i, 0, 0)
+		}
+		if d.After(until) {
+			break
+		}
+		// a yearly event on Feb 29 only happens in leap years
+		if freq == "YEARLY" && d.Day() != start.Day() {
+			continue
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// parseICalDate reads the day of an iCal DATE or DATE-TIME value.
+func parseICalDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid iCal date %q", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// hours returns the hours of the day work starts and ends.
+func (c *Calendar) hours() (int, int) {
+	if c.StartHour == 0 && c.EndHour == 0 {
+		return 9, 17
+	}
+	return c.StartHour, c.EndHour
+}
+
+// loc returns the time zone of the calendar.
+func (c *Calendar) loc() *time.Location {
+	c.Load()
+	if c.location == nil {
+		return time.UTC
+	}
+	return c.location
+}
+
+// workDay reports whether the day starting at midnight day is a working
+// day.
+func (c *Calendar) workDay(day time.Time) bool {
+	c.Load()
+	if c.holidays[day.Format("2006-01-02")] {
+		return false
+	}
+	if len(c.WorkDays) == 0 {
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	}
+	for _, d := range c.WorkDays {
+		if strings.EqualFold(d, day.Weekday().String()[:3]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration returns the working time between from and to. A nil
+// Calendar counts all of it.
+func (c *Calendar) Duration(from time.Time, to time.Time) time.Duration {
+	if c == nil {
+		return to.Sub(from)
+	}
+	startHour, endHour := c.hours()
+	var d time.Duration
+	f := from.In(c.loc())
+	for day := time.Date(f.Year(), f.Month(), f.Day(), 0, 0, 0, 0, c.loc()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !c.workDay(day) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), startHour, 0, 0, 0, c.loc())
+		end := time.Date(day.Year(), day.Month(), day.Day(), endHour, 0, 0, 0, c.loc())
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			d += end.Sub(start)
+		}
+	}
+	return d
+}
+
+// period is a span of time.
+type period struct {
+	from, to time.Time
+}
+
+// daysOff returns the non-working days between from and to, clipped
+// to that range.
+func (c *Calendar) daysOff(from time.Time, to time.Time) []period {
+	if c == nil {
+		return nil
+	}
+	var off []period
+	f := from.In(c.loc())
+	for day := time.Date(f.Year(), f.Month(), f.Day(), 0, 0, 0, 0, c.loc()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if c.workDay(day) {
+			continue
+		}
+		p := period{day, day.AddDate(0, 0, 1)}
+		if p.from.Before(from) {
+			p.from = from
+		}
+		if p.to.After(to) {
+			p.to = to
+		}
+		// merge consecutive days off, like a weekend
+		if n := len(off); n > 0 && off[n-1].to.Equal(p.from) {
+			off[n-1].to = p.to
+			continue
+		}
+		off = append(off, p)
+	}
+	return off
+}
+
+// location returns the time zone days and times are displayed in.
+func (c Config) location() (*time.Location, error) {
+	if c.TimeZone == "" && c.Calendar != nil {
+		return c.Calendar.loc(), nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// workingDuration is how long a pull request has been open in working
+// time according to cal, not counting the time it spent closed before
+// being reopened.
+func workingDuration(pr SummarizedPullRequest, cal *Calendar) time.Duration {
+	if cal == nil {
+		return openDuration(pr)
+	}
+	var d time.Duration
+	from := pr.OpenedAt
+	for _, g := range append(append([]Gap{}, pr.Gaps...), Gap{ClosedAt: pr.ClosedAt}) {
+		d += cal.Duration(from, g.ClosedAt)
+		from = g.ReopenedAt
+	}
+	return d
+}
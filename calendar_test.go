@@ -0,0 +1,160 @@
+package prmonitor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHolidays = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20180219
+SUMMARY:Presidents'
+  Day
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20181224
+DTEND;VALUE=DATE:20181226
+SUMMARY:Christmas
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICal(t *testing.T) {
+	days, err := ParseICal(strings.NewReader(testHolidays))
+	if err != nil {
+		panic(err)
+	}
+	var got []string
+	for _, d := range days {
+		got = append(got, d.Format("2006-01-02"))
+	}
+	if fmt.Sprint(got) != "[2018-02-19 2018-12-24 2018-12-25]" {
+		t.Logf("ERROR: unexpected holidays %v", got)
+		t.Fail()
+	}
+}
+
+func TestParseICalRecurring(t *testing.T) {
+	tests := []struct {
+		event    string
+		expected string
+	}{
+		{"DTSTART;VALUE=DATE:20181225\nRRULE:FREQ=YEARLY;COUNT=3", "[2018-12-25 2019-12-25 2020-12-25]"},
+		{"DTSTART;VALUE=DATE:20181224\nDTEND;VALUE=DATE:20181226\nRRULE:FREQ=YEARLY;UNTIL=20191231", "[2018-12-24 2018-12-25 2019-12-24 2019-12-25]"},
+		{"DTSTART;VALUE=DATE:20180102\nRRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20180131T000000Z", "[2018-01-02 2018-01-16 2018-01-30]"},
+		{"DTSTART;VALUE=DATE:20160229\nRRULE:FREQ=YEARLY;COUNT=2", "[2016-02-29 2020-02-29]"},
+	}
+	for _, test := range tests {
+		days, err := ParseICal(strings.NewReader("BEGIN:VEVENT\n" + test.event + "\nEND:VEVENT\n"))
+		if err != nil {
+			t.Logf("ERROR: %s: unexpected error %s", test.event, err)
+			t.Fail()
+			continue
+		}
+		var got []string
+		for _, d := range days {
+			got = append(got, d.Format("2006-01-02"))
+		}
+		if fmt.Sprint(got) != test.expected {
+			t.Logf("ERROR: %s: expected %s, but got %v", test.event, test.expected, got)
+			t.Fail()
+		}
+	}
+
+	days, err := ParseICal(strings.NewReader("BEGIN:VEVENT\nDTSTART;VALUE=DATE:20181225\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"))
+	if err != nil || len(days) != icalHorizon+1 {
+		t.Logf("ERROR: expected a rule without an end to be expanded for %d years, but got %d days, %v", icalHorizon, len(days), err)
+		t.Fail()
+	}
+	for _, rule := range []string{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "FREQ=MONTHLY"} {
+		if _, err := ParseICal(strings.NewReader("BEGIN:VEVENT\nDTSTART;VALUE=DATE:20181122\nRRULE:" + rule + "\nEND:VEVENT\n")); err == nil {
+			t.Logf("ERROR: %s: expected an unsupported rule to be reported", rule)
+			t.Fail()
+		}
+	}
+}
+
+func TestCalendarDuration(t *testing.T) {
+	cal := &Calendar{TimeZone: "America/Los_Angeles"}
+	if err := cal.Load(); err != nil {
+		panic(err)
+	}
+	cal.holidays["2018-02-19"] = true
+	loc := cal.loc()
+
+	tests := []struct {
+		from, to time.Time
+		expected time.Duration
+	}{
+		// friday evening to monday morning
+		{time.Date(2018, 2, 9, 18, 0, 0, 0, loc), time.Date(2018, 2, 12, 10, 0, 0, 0, loc), time.Hour},
+		// within a day
+		{time.Date(2018, 2, 13, 10, 0, 0, 0, loc), time.Date(2018, 2, 13, 12, 30, 0, 0, loc), 150 * time.Minute},
+		// over a holiday weekend
+		{time.Date(2018, 2, 16, 9, 0, 0, 0, loc), time.Date(2018, 2, 20, 17, 0, 0, 0, loc), 16 * time.Hour},
+	}
+	for _, test := range tests {
+		if got := cal.Duration(test.from, test.to); got != test.expected {
+			t.Logf("ERROR: %s to %s: expected %s, but got %s", test.from, test.to, test.expected, got)
+			t.Fail()
+		}
+	}
+
+	var none *Calendar
+	if got := none.Duration(tests[0].from, tests[0].to); got != 64*time.Hour {
+		t.Logf("ERROR: expected wall clock time without a calendar, but got %s", got)
+		t.Fail()
+	}
+}
+
+func TestDaysOff(t *testing.T) {
+	cal := &Calendar{WorkDays: []string{"Mon", "Tue", "Wed", "Thu"}}
+	if err := cal.Load(); err != nil {
+		panic(err)
+	}
+	from := time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC)
+	off := cal.daysOff(from, from.Add(96*time.Hour))
+	if len(off) != 1 || !off[0].from.Equal(time.Date(2018, 2, 9, 0, 0, 0, 0, time.UTC)) || !off[0].to.Equal(time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Logf("ERROR: expected friday to sunday off, but got %v", off)
+		t.Fail()
+	}
+}
+
+func TestCalendarLoadsLazily(t *testing.T) {
+	cal := &Calendar{TimeZone: "America/Los_Angeles"}
+	if cal.loc().String() != "America/Los_Angeles" {
+		t.Logf("ERROR: expected the time zone to be loaded on first use, but got %s", cal.loc())
+		t.Fail()
+	}
+	from := time.Date(2018, 2, 13, 9, 0, 0, 0, time.UTC)
+	if got := cal.Duration(from, from.Add(2*time.Hour)); got != 0 {
+		t.Logf("ERROR: expected no working time before 9 in Los Angeles, but got %s", got)
+		t.Fail()
+	}
+}
+
+func TestCalendarHours(t *testing.T) {
+	tests := []struct {
+		start, end int
+		valid      bool
+	}{
+		{0, 0, true},
+		{8, 18, true},
+		{0, 24, true},
+		{10, 0, false},
+		{17, 9, false},
+		{9, 9, false},
+		{-1, 17, false},
+		{9, 25, false},
+	}
+	for _, test := range tests {
+		cal := &Calendar{StartHour: test.start, EndHour: test.end}
+		if err := cal.Load(); (err == nil) != test.valid {
+			t.Logf("ERROR: hours %d to %d: expected valid %t, but got %v", test.start, test.end, test.valid, err)
+			t.Fail()
+		}
+	}
+}
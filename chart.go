@@ -0,0 +1,100 @@
+package prmonitor
+
+import (
+	"sort"
+	"time"
+)
+
+// chartAxis is the span of time a chart covers: the last ten days,
+// starting and ending at midnight in the display time zone.
+type chartAxis struct {
+	Start, End time.Time
+	Location   *time.Location
+
+	// days off in the working calendar, if there is one.
+	DaysOff []period
+}
+
+// newChartAxis works out the axis of a chart drawn at now.
+func newChartAxis(now time.Time, config Config) chartAxis {
+	loc, err := config.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	end := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	start := end.AddDate(0, 0, -10)
+	return chartAxis{Start: start, End: end, Location: loc, DaysOff: config.Calendar.daysOff(start, end)}
+}
+
+// Position returns where t falls on the axis, as a fraction of its
+// width. Times outside the axis fall outside 0 to 1.
+func (a chartAxis) Position(t time.Time) float64 {
+	return t.Sub(a.Start).Hours() / a.End.Sub(a.Start).Hours()
+}
+
+// Labels names the days on the axis, oldest first.
+func (a chartAxis) Labels() []string {
+	var labels []string
+	for i := 10; i > 0; i-- {
+		if i == 1 {
+			labels = append(labels, "today")
+		} else {
+			labels = append(labels, a.End.AddDate(0, 0, -i).Format("Mon Jan 2"))
+		}
+	}
+	return labels
+}
+
+// chartBar is how a single PR is drawn on a chart.
+type chartBar struct {
+	PR SummarizedPullRequest
+
+	// the customization the PR is colored with, after repo, swimlane,
+	// bot and size overrides.
+	Customization Customization
+
+	Age   time.Duration
+	Tier  string
+	Color string
+
+	// the periods the PR was open.
+	Segments []period
+}
+
+// newChartBar works out the color and extent of pr, in the swimlane
+// named group.
+func newChartBar(pr SummarizedPullRequest, config Config, group string) chartBar {
+	colors := config
+	colors.Customization = customizationFor(pr, config, group).forSize(sizeBucket(pr))
+	b := chartBar{PR: pr, Customization: colors.Customization, Age: reviewDuration(pr, config)}
+	b.Tier = getTier(colors, b.Age.Hours(), pr.State)
+	b.Color = getColor(colors, b.Age.Hours(), pr.State)
+	if pr.State == "open" && pr.CIStatus == "failure" && config.CI.FailingColor != "" {
+		b.Color = config.CI.FailingColor
+	}
+	from := pr.OpenedAt
+	for _, g := range append(append([]Gap{}, pr.Gaps...), Gap{ClosedAt: pr.ClosedAt}) {
+		b.Segments = append(b.Segments, period{from, g.ClosedAt})
+		from = g.ReopenedAt
+	}
+	return b
+}
+
+// sortPRs sorts prs as sortBy asks, falling back to date and reporting
+// any unknown sort keys to diag.
+func sortPRs(prs SummarizedPullRequests, sortBy SortBy, diag *Diagnostics) {
+	keys, err := sortBy.Keys()
+	if err != nil {
+		diag.Report("%s, sorting by date", err)
+	}
+	sort.Sort(ByKeys{prs, keys})
+}
+
+// chartTitle is the heading of a dashboard or chart.
+func chartTitle(config Config) string {
+	if config.Title == "" {
+		return "Recent Pull Requests"
+	}
+	return config.Title
+}
@@ -0,0 +1,165 @@
+package prmonitor
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"strings"
+	"time"
+)
+
+// CI controls how the build status of pull requests is shown.
+type CI struct {
+	// fetch the combined status and check runs of each open PR's head
+	// commit. Costs two requests per PR with the REST API, made by
+	// Config.Concurrency workers at once; the GraphQL API always
+	// includes them.
+	Enabled bool
+
+	// measure the age of a PR with a failing build only up to when it
+	// failed, since it is waiting on its author rather than reviewers.
+	StopClock bool
+
+	// optional color for PRs with a failing build, instead of the
+	// color for their age.
+	FailingColor string
+}
+
+// ciResult combines commit statuses and check runs into a single
+// state, the same way github does: any failure fails the commit, and
+// otherwise anything unfinished keeps it pending.
+type ciResult struct {
+	failing, pending, passing bool
+
+	// the time of the earliest failure, when the build started failing.
+	failedAt time.Time
+}
+
+// add records a status or check run. status is only set for check
+// runs, which report whether they finished separately from how.
+func (c *ciResult) add(status string, conclusion string, at time.Time) {
+	status, conclusion = strings.ToLower(status), strings.ToLower(conclusion)
+	if status != "" && status != "completed" {
+		c.pending = true
+		return
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		c.passing = true
+	case "pending", "expected", "":
+		c.pending = true
+	default:
+		// failure, error, cancelled, timed_out, action_required...
+		c.failing = true
+		if !at.IsZero() && (c.failedAt.IsZero() || at.Before(c.failedAt)) {
+			c.failedAt = at
+		}
+	}
+}
+
+// state returns "failure", "pending", "success", or "" if nothing was
+// reported.
+func (c *ciResult) state() string {
+	switch {
+	case c.failing:
+		return "failure"
+	case c.pending:
+		return "pending"
+	case c.passing:
+		return "success"
+	}
+	return ""
+}
+
+// TrackCIStatus looks up the combined status and check runs of the
+// head commit of each open pull request and records whether its build
+// is passing, failing or pending. PRs whose status can't be fetched
+// are passed on unchanged and reported to diag.
+func TrackCIStatus(ctx context.Context, in <-chan SummarizedPullRequest, client *github.Client, limiter *RateLimiter, diag *Diagnostics) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if v.State != "open" || v.HeadSHA == "" {
+				out <- v
+				continue
+			}
+
+			var result ciResult
+			var status *github.CombinedStatus
+			err := limiter.Do(ctx, func() (*github.Response, error) {
+				var resp *github.Response
+				var err error
+				status, resp, err = client.Repositories.GetCombinedStatus(ctx, v.Owner, v.Repo, v.HeadSHA, &github.ListOptions{PerPage: 100})
+				return resp, err
+			})
+			if err != nil {
+				diag.Report("%s/%s#%d: couldn't fetch commit status: %s", v.Owner, v.Repo, v.Number, err)
+				out <- v
+				continue
+			}
+			for _, s := range status.Statuses {
+				var at time.Time
+				if s.UpdatedAt != nil {
+					at = *s.UpdatedAt
+				}
+				result.add("", s.GetState(), at)
+			}
+
+			var checks *github.ListCheckRunsResults
+			err = limiter.Do(ctx, func() (*github.Response, error) {
+				var resp *github.Response
+				var err error
+				checks, resp, err = client.Checks.ListCheckRunsForRef(ctx, v.Owner, v.Repo, v.HeadSHA, &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}})
+				return resp, err
+			})
+			if err != nil {
+				diag.Report("%s/%s#%d: couldn't fetch check runs: %s", v.Owner, v.Repo, v.Number, err)
+			} else {
+				for _, c := range checks.CheckRuns {
+					var at time.Time
+					if c.CompletedAt != nil {
+						at = c.CompletedAt.Time
+					}
+					result.add(c.GetStatus(), c.GetConclusion(), at)
+				}
+			}
+
+			v.CIStatus = result.state()
+			v.CIFailedAt = result.failedAt
+			out <- v
+		}
+		close(out)
+	}()
+	return out
+}
+
+// reviewDuration is how long a pull request has been waiting for
+// review, in working hours if a Calendar is configured. With
+// CI.StopClock, PRs with a failing build stop aging when the build
+// failed.
+func reviewDuration(pr SummarizedPullRequest, config Config) time.Duration {
+	if config.CI.StopClock && pr.CIStatus == "failure" && pr.CIFailedAt.After(pr.OpenedAt) && pr.CIFailedAt.Before(pr.ClosedAt) {
+		pr.ClosedAt = pr.CIFailedAt
+	}
+	return workingDuration(pr, config.Calendar)
+}
+
+// ciBadge renders a small icon showing the build status of a PR, if
+// CI is enabled and the PR is open.
+func ciBadge(pr SummarizedPullRequest, config Config) string {
+	if !config.CI.Enabled || pr.State != "open" {
+		return ""
+	}
+	switch pr.CIStatus {
+	case "success":
+		return "<span title='build passing' style='color: " + config.Customization.PassiveColor + "; margin-right: 0.3em;'>&#10004;</span>"
+	case "failure":
+		color := config.CI.FailingColor
+		if color == "" {
+			color = config.Customization.AlertColor
+		}
+		return "<span title='build failing' style='color: " + color + "; margin-right: 0.3em;'>&#10008;</span>"
+	case "pending":
+		return "<span title='build pending' style='color: " + config.Customization.WarningColor + "; margin-right: 0.3em;'>&#9679;</span>"
+	}
+	return ""
+}
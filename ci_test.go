@@ -0,0 +1,166 @@
+package prmonitor
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCIResult(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		statuses [][2]string
+		expected string
+	}{
+		{nil, ""},
+		{[][2]string{{"", "success"}, {"completed", "success"}}, "success"},
+		{[][2]string{{"", "success"}, {"in_progress", ""}}, "pending"},
+		{[][2]string{{"", "pending"}, {"completed", "failure"}}, "failure"},
+		{[][2]string{{"", "error"}}, "failure"},
+		{[][2]string{{"COMPLETED", "SKIPPED"}}, "success"},
+	}
+	for _, test := range tests {
+		var c ciResult
+		for _, s := range test.statuses {
+			c.add(s[0], s[1], now)
+		}
+		if got := c.state(); got != test.expected {
+			t.Logf("ERROR: %v: expected %q, but got %q", test.statuses, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestTrackCIStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/brentdrich/prmonitor/commits/abc/status":
+			w.Write([]byte(`{"statuses": [{"state": "success", "updated_at": "2018-02-01T00:00:00Z"}]}`))
+		case "/repos/brentdrich/prmonitor/commits/abc/check-runs":
+			w.Write([]byte(`{"total_count": 2, "check_runs": [
+				{"status": "completed", "conclusion": "failure", "completed_at": "2018-02-03T00:00:00Z"},
+				{"status": "completed", "conclusion": "failure", "completed_at": "2018-02-02T00:00:00Z"}
+			]}`))
+		case "/repos/brentdrich/prmonitor/commits/def/status":
+			w.Write([]byte(`{"statuses": [{"state": "success", "updated_at": "2018-02-01T00:00:00Z"}]}`))
+		case "/repos/brentdrich/prmonitor/commits/def/check-runs":
+			w.Write([]byte(`{"total_count": 0, "check_runs": []}`))
+		default:
+			t.Logf("ERROR: unexpected request %s", r.URL.Path)
+			t.Fail()
+			w.WriteHeader(404)
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan SummarizedPullRequest)
+	out := TrackCIStatus(context.Background(), in, client, NewRateLimiter(), nil)
+	go func() {
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 1, State: "open", HeadSHA: "abc"}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, State: "open", HeadSHA: "def"}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 3, State: "closed", HeadSHA: "123"}
+		close(in)
+	}()
+
+	expected := map[int]string{1: "failure", 2: "success", 3: ""}
+	for pr := range out {
+		if pr.CIStatus != expected[pr.Number] {
+			t.Logf("ERROR: #%d: expected status %q, but got %q", pr.Number, expected[pr.Number], pr.CIStatus)
+			t.Fail()
+		}
+		if pr.Number == 1 && !pr.CIFailedAt.Equal(time.Date(2018, 2, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Logf("ERROR: expected the time of the first failure, but got %s", pr.CIFailedAt)
+			t.Fail()
+		}
+	}
+}
+
+func TestDashboardCIConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, most := 0, 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/docker/swarmkit/pulls" && r.URL.Query().Get("state") == "open":
+			w.Write([]byte(`[
+				{"number": 1, "state": "open", "created_at": "2016-10-01T00:00:00Z", "head": {"sha": "abc"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}},
+				{"number": 2, "state": "open", "created_at": "2016-10-01T00:00:00Z", "head": {"sha": "def"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			mu.Lock()
+			active++
+			if active > most {
+				most = active
+			}
+			mu.Unlock()
+			time.Sleep(100 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			w.Write([]byte(`{"statuses": []}`))
+		case strings.HasSuffix(r.URL.Path, "/check-runs"):
+			w.Write([]byte(`{"total_count": 0, "check_runs": []}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+	Dashboard(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}, CI: CI{Enabled: true}, Concurrency: 2, Customization: GetCustomizations()}, client)(w, req)
+	if most != 2 {
+		t.Logf("ERROR: expected the status of both PRs to be fetched at once, but got at most %d at a time", most)
+		t.Fail()
+	}
+}
+
+func TestReviewDuration(t *testing.T) {
+	opened := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	pr := SummarizedPullRequest{
+		OpenedAt:   opened,
+		ClosedAt:   opened.Add(48 * time.Hour),
+		CIStatus:   "failure",
+		CIFailedAt: opened.Add(6 * time.Hour),
+	}
+	if got := reviewDuration(pr, Config{}); got != 48*time.Hour {
+		t.Logf("ERROR: expected 48h without stopping the clock, but got %s", got)
+		t.Fail()
+	}
+	if got := reviewDuration(pr, Config{CI: CI{StopClock: true}}); got != 6*time.Hour {
+		t.Logf("ERROR: expected 6h with the clock stopped, but got %s", got)
+		t.Fail()
+	}
+}
+
+func TestCIBadge(t *testing.T) {
+	config := Config{CI: CI{Enabled: true}, Customization: GetCustomizations()}
+	open := SummarizedPullRequest{State: "open", CIStatus: "failure"}
+	if got := ciBadge(open, config); !strings.Contains(got, "build failing") {
+		t.Logf("ERROR: expected a failing badge, but got %q", got)
+		t.Fail()
+	}
+	closed := open
+	closed.State = "closed"
+	if got := ciBadge(closed, config); got != "" {
+		t.Logf("ERROR: expected no badge on a closed PR, but got %q", got)
+		t.Fail()
+	}
+	config.CI.Enabled = false
+	if got := ciBadge(open, config); got != "" {
+		t.Logf("ERROR: expected no badge with CI disabled, but got %q", got)
+		t.Fail()
+	}
+}
@@ -27,40 +27,52 @@ func main() {
 		panic(err)
 	}
 
-	var client *github.Client
+	if t.Calendar != nil {
+		if err := t.Calendar.Load(); err != nil {
+			panic(err)
+		}
+	}
+
+	// cache github responses so unchanged pull request lists are
+	// answered with a 304, which doesn't count against the rate limit.
+	var cache prmonitor.Cache = prmonitor.NewMemoryCache(prmonitor.DefaultMemoryCacheSize)
+	if dir := strings.TrimSpace(os.Getenv("CACHE_DIR")); dir != "" {
+		cache, err = prmonitor.NewDiskCache(dir)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var hc *http.Client
 	if t.GithubToken == "" {
 		tp := github.BasicAuthTransport{
 			Username: t.GithubUser,
 			Password: t.GithubPass,
 		}
-		client = github.NewClient(tp.Client())
+		hc = tp.Client()
 	} else {
-		tc := oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(
+		hc = oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: t.GithubToken},
 		))
-		client = github.NewClient(tc)
 	}
+	hc.Transport = prmonitor.NewCachingTransport(hc.Transport, cache)
+	client := github.NewClient(hc)
 
-	defaultCustom := prmonitor.GetCustomizations()
-	if t.Customization.PassiveColor == "" {
-		t.Customization.PassiveColor = defaultCustom.PassiveColor
-	}
-	if t.Customization.WarningColor == "" {
-		t.Customization.WarningColor = defaultCustom.WarningColor
-	}
-	if t.Customization.AlertColor == "" {
-		t.Customization.AlertColor = defaultCustom.AlertColor
-	}
-	if t.Customization.ClosedColor == "" {
-		t.Customization.ClosedColor = defaultCustom.ClosedColor
-	}
-	if t.Customization.PassiveTime == 0 {
-		t.Customization.PassiveTime = defaultCustom.PassiveTime
-	}
-	if t.Customization.WarningTime == 0 {
-		t.Customization.WarningTime = defaultCustom.WarningTime
+	t.Customization = t.Customization.WithDefaults()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tui":
+			runTUI(t, client, os.Args[2:])
+			return
+		default:
+			log.Fatalf("unknown command %q, expected tui or none to serve the dashboard", os.Args[1])
+		}
 	}
 
 	http.HandleFunc("/", prmonitor.BasicAuth(t.DashboardUser, t.DashboardPass, prmonitor.Timestamp(prmonitor.Dashboard(t, client))))
+	http.HandleFunc("/chart.svg", prmonitor.BasicAuth(t.DashboardUser, t.DashboardPass, prmonitor.Timestamp(prmonitor.ChartSVG(t, client))))
+	http.HandleFunc("/chart.png", prmonitor.BasicAuth(t.DashboardUser, t.DashboardPass, prmonitor.Timestamp(prmonitor.ChartPNG(t, client))))
+	http.HandleFunc("/me", prmonitor.BasicAuth(t.DashboardUser, t.DashboardPass, prmonitor.Timestamp(prmonitor.ReviewQueue(t, client))))
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", os.Getenv("PORT")), nil))
 }
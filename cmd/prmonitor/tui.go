@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/brentdrich/prmonitor"
+	"github.com/google/go-github/github"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// runTUI shows the dashboard in the terminal until q is pressed.
+func runTUI(t prmonitor.Config, client *github.Client, args []string) {
+	u := prmonitor.NewTUI(t, client)
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	flags.DurationVar(&u.Refresh, "refresh", u.Refresh, "how often to retrieve pull requests again")
+	flags.BoolVar(&u.TrueColor, "truecolor", os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit", "draw 24 bit colors")
+	flags.Parse(args)
+	if u.Refresh <= 0 {
+		log.Fatalf("-refresh must be a positive duration, but got %s", u.Refresh)
+	}
+
+	u.Size = terminalSize
+	u.Open = openURL
+
+	// raw mode passes each key straight through, and is undone on
+	// the way out so the shell still works
+	saved, err := stty("-g")
+	if err != nil {
+		log.Fatalf("tui needs a terminal: %s", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		log.Fatalf("tui needs a terminal: %s", err)
+	}
+	err = u.Run(os.Stdin, os.Stdout)
+	stty(strings.TrimSpace(saved))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stty runs stty on the terminal prmonitor was started in.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize asks stty for the width and height of the terminal,
+// falling back to 80x24.
+func terminalSize() (int, int) {
+	var width, height int
+	out, err := stty("size")
+	if err != nil {
+		return 80, 24
+	}
+	if _, err := fmt.Sscan(out, &height, &width); err != nil || width == 0 || height == 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// openURL opens url in the desktop's browser.
+func openURL(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Run()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Run()
+	default:
+		return exec.Command("xdg-open", url).Run()
+	}
+}
@@ -0,0 +1,180 @@
+package prmonitor
+
+import (
+	"bufio"
+	"context"
+	"github.com/google/go-github/github"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// codeOwnersPaths are the locations github looks for a CODEOWNERS file,
+// in order.
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwners maps file paths to the users and teams that own them,
+// following the rules of a github CODEOWNERS file.
+type CodeOwners struct {
+	rules []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// ParseCodeOwners reads a CODEOWNERS file. Lines with patterns that
+// can't be understood are skipped.
+func ParseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	c := &CodeOwners{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pattern, err := codeOwnersPattern(fields[0])
+		if err != nil {
+			continue
+		}
+		c.rules = append(c.rules, codeOwnersRule{pattern: pattern, owners: fields[1:]})
+	}
+	return c, s.Err()
+}
+
+// codeOwnersPattern converts a gitignore style pattern into a regular
+// expression matching the paths it covers, including everything under
+// a matching directory. A pattern ending in a lone "*", like docs/*,
+// only covers the files directly in that directory.
+func codeOwnersPattern(p string) (*regexp.Regexp, error) {
+	// patterns with a slash anywhere but the end are relative to the
+	// root, others match at any depth
+	anchored := strings.Contains(strings.TrimSuffix(p, "/"), "/")
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "/"), "/")
+	subtree := "(/.*)?"
+	if p == "*" || strings.HasSuffix(p, "/*") {
+		subtree = ""
+	}
+
+	expr := "^"
+	if !anchored {
+		expr += "(.*/)?"
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			expr += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			expr += ".*"
+			i++
+		case p[i] == '*':
+			expr += "[^/]*"
+		case p[i] == '?':
+			expr += "[^/]"
+		default:
+			expr += regexp.QuoteMeta(p[i : i+1])
+		}
+	}
+	return regexp.Compile(expr + subtree + "$")
+}
+
+// Owners returns the owners of file. As on github, the last matching
+// rule wins, and a rule without owners leaves the file unowned.
+func (c *CodeOwners) Owners(file string) []string {
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].pattern.MatchString(file) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// fetchCodeOwners downloads and parses a repo's CODEOWNERS file. A repo
+// without one gets an empty CodeOwners.
+func fetchCodeOwners(ctx context.Context, client *github.Client, limiter *RateLimiter, owner string, repo string) (*CodeOwners, error) {
+	for _, path := range codeOwnersPaths {
+		var file *github.RepositoryContent
+		err := limiter.Do(ctx, func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			file, _, resp, err = client.Repositories.GetContents(ctx, owner, repo, path, nil)
+			return resp, err
+		})
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response != nil && e.Response.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		return ParseCodeOwners(strings.NewReader(content))
+	}
+	return &CodeOwners{}, nil
+}
+
+// FilterByCodeOwners records the code owners of the files each pull
+// request changes, and drops the PRs that don't need a review from one
+// of teams (provided the list isn't empty). CODEOWNERS files are
+// fetched once per repo. PRs whose owners can't be worked out are kept
+// and reported to diag.
+func FilterByCodeOwners(ctx context.Context, in <-chan SummarizedPullRequest, client *github.Client, limiter *RateLimiter, files *ChangedFiles, teams []string, diag *Diagnostics) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		owners := map[string]*CodeOwners{}
+		for v := range in {
+			key := v.Owner + "/" + v.Repo
+			co, ok := owners[key]
+			if !ok {
+				var err error
+				co, err = fetchCodeOwners(ctx, client, limiter, v.Owner, v.Repo)
+				if err != nil {
+					diag.Report("%s: couldn't fetch CODEOWNERS: %s", key, err)
+				}
+				owners[key] = co
+			}
+			if co == nil {
+				out <- v
+				continue
+			}
+
+			changed, err := files.List(ctx, v)
+			if err != nil {
+				diag.Report("%s#%d: couldn't list changed files: %s", key, v.Number, err)
+				out <- v
+				continue
+			}
+			seen := map[string]bool{}
+			for _, f := range changed {
+				for _, o := range co.Owners(f) {
+					if !seen[strings.ToLower(o)] {
+						seen[strings.ToLower(o)] = true
+						v.CodeOwners = append(v.CodeOwners, o)
+					}
+				}
+			}
+
+			if len(teams) == 0 {
+				out <- v
+				continue
+			}
+			for _, t := range teams {
+				// teams may be configured with or without the leading @
+				if seen[strings.ToLower("@"+strings.TrimPrefix(t, "@"))] {
+					out <- v
+					break
+				}
+			}
+		}
+		close(out)
+	}()
+	return out
+}
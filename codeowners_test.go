@@ -0,0 +1,96 @@
+package prmonitor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testCodeOwners = `
+# default owners
+*                     @brentdrich/core
+*.md                  @brentdrich/docs
+docs/*                @brentdrich/writers
+/services/payments/   @brentdrich/payments @brentdrich/core
+apps/**/config.yml    @brentdrich/ops
+vendor/
+`
+
+func TestCodeOwners(t *testing.T) {
+	co, err := ParseCodeOwners(strings.NewReader(testCodeOwners))
+	if err != nil {
+		panic(err)
+	}
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"main.go", "[@brentdrich/core]"},
+		{"docs/README.md", "[@brentdrich/writers]"},
+		{"docs/sub/b.md", "[@brentdrich/docs]"},
+		{"docs/sub/b.png", "[@brentdrich/core]"},
+		{"services/payments/charge.go", "[@brentdrich/payments @brentdrich/core]"},
+		{"other/services/payments/charge.go", "[@brentdrich/core]"},
+		{"apps/web/config.yml", "[@brentdrich/ops]"},
+		{"apps/web/prod/config.yml", "[@brentdrich/ops]"},
+		{"lib/vendor/lib.go", "[]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(co.Owners(test.file)); got != test.expected {
+			t.Logf("ERROR: %s: expected owners %s, but got %s", test.file, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestFilterByCodeOwners(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/brentdrich/monorepo/contents/.github/CODEOWNERS":
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		case "/repos/brentdrich/monorepo/contents/CODEOWNERS":
+			fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "content": "%s"}`, base64.StdEncoding.EncodeToString([]byte(testCodeOwners)))
+		case "/repos/brentdrich/monorepo/pulls/1/files":
+			w.Write([]byte(`[{"filename": "services/payments/charge.go"}]`))
+		case "/repos/brentdrich/monorepo/pulls/2/files":
+			w.Write([]byte(`[{"filename": "README.md"}]`))
+		default:
+			t.Logf("ERROR: unexpected request %s", r.URL.Path)
+			t.Fail()
+			w.WriteHeader(404)
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan SummarizedPullRequest)
+	limiter := NewRateLimiter()
+	out := FilterByCodeOwners(context.Background(), in, client, limiter, NewChangedFiles(client, limiter), []string{"brentdrich/payments"}, nil)
+	go func() {
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "monorepo", Number: 1}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "monorepo", Number: 2}
+		close(in)
+	}()
+
+	var prs []SummarizedPullRequest
+	for pr := range out {
+		prs = append(prs, pr)
+	}
+	if len(prs) != 1 || prs[0].Number != 1 {
+		t.Logf("ERROR: expected only the payments PR, but got %+v", prs)
+		t.Fail()
+		return
+	}
+	if fmt.Sprint(prs[0].CodeOwners) != "[@brentdrich/payments @brentdrich/core]" {
+		t.Logf("ERROR: unexpected code owners %v", prs[0].CodeOwners)
+		t.Fail()
+	}
+}
@@ -0,0 +1,86 @@
+package prmonitor
+
+import "strings"
+
+// withFallback fills in the fields of c that aren't set from d.
+func (c Customization) withFallback(d Customization) Customization {
+	// times set without tiers are the shorthand for tiers, so they
+	// mustn't be overridden by d's tiers. Colors set without tiers
+	// recolor d's tiers of the same name instead.
+	if c.Tiers == nil && c.PassiveTime == 0 && c.WarningTime == 0 && d.Tiers != nil {
+		c.Tiers = c.recolor(d.Tiers)
+	}
+	if c.PassiveColor == "" {
+		c.PassiveColor = d.PassiveColor
+	}
+	if c.WarningColor == "" {
+		c.WarningColor = d.WarningColor
+	}
+	if c.AlertColor == "" {
+		c.AlertColor = d.AlertColor
+	}
+	if c.ClosedColor == "" {
+		c.ClosedColor = d.ClosedColor
+	}
+	if c.PassiveTime == 0 {
+		c.PassiveTime = d.PassiveTime
+	}
+	if c.WarningTime == 0 {
+		c.WarningTime = d.WarningTime
+	}
+	if c.SizeScale == nil {
+		c.SizeScale = d.SizeScale
+	}
+	if c.BackgroundColor == "" {
+		c.BackgroundColor = d.BackgroundColor
+	}
+	if c.TextColor == "" {
+		c.TextColor = d.TextColor
+	}
+	if c.GridColor == "" {
+		c.GridColor = d.GridColor
+	}
+	if c.Font == "" {
+		c.Font = d.Font
+	}
+	if c.Theme == "" {
+		c.Theme = d.Theme
+	}
+	if c.Palette == "" {
+		c.Palette = d.Palette
+	}
+	c.Gradient = c.Gradient || d.Gradient
+	c.Icons = c.Icons || d.Icons
+	return c
+}
+
+// recolor returns a copy of tiers with those named passive, warning
+// and alert in c's colors of the same name, where c sets them.
+func (c Customization) recolor(tiers []Tier) []Tier {
+	colors := map[string]string{"passive": c.PassiveColor, "warning": c.WarningColor, "alert": c.AlertColor}
+	recolored := append([]Tier{}, tiers...)
+	for i, t := range recolored {
+		if color := colors[strings.ToLower(t.Name)]; color != "" {
+			recolored[i].Color = color
+		}
+	}
+	return recolored
+}
+
+// override resolves the customization o of a repo, swimlane or bots
+// on top of c: o's own theme and palette, if it names them, win over
+// c, and anything else it doesn't set comes from c.
+func (c Customization) override(o Customization) Customization {
+	if palette, ok := palettes[strings.ToLower(o.Palette)]; ok && o.Palette != "" {
+		o = o.withFallback(Customization{
+			PassiveColor: palette.PassiveColor,
+			WarningColor: palette.WarningColor,
+			AlertColor:   palette.AlertColor,
+			ClosedColor:  palette.ClosedColor,
+		})
+	}
+	if theme, ok := themes[strings.ToLower(o.Theme)]; ok && o.Theme != "" {
+		o = o.withFallback(theme)
+	}
+	return o.withFallback(c)
+}
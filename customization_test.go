@@ -0,0 +1,56 @@
+package prmonitor
+
+import "testing"
+
+func TestCustomizationFallback(t *testing.T) {
+	c := Customization{PassiveTime: 240, WarningTime: 480}.withFallback(GetCustomizations())
+	if c.PassiveTime != 240 || c.AlertColor != GetCustomizations().AlertColor {
+		t.Logf("ERROR: expected set fields to be kept and others filled in, but got %+v", c)
+		t.Fail()
+	}
+}
+
+func TestCustomizationOverrideTiers(t *testing.T) {
+	global := GetCustomizations()
+	global.Tiers = []Tier{
+		{Name: "passive", After: 0, Color: "#00ff00"},
+		{Name: "warning", After: 12, Color: "#ffff00"},
+		{Name: "alert", After: 24, Color: "#ff0000"},
+		{Name: "overdue", After: 72, Color: "#000000"},
+	}
+
+	// shorthand colors recolor the inherited tiers of the same name
+	c := global.override(Customization{AlertColor: "#ff00ff"})
+	if len(c.Tiers) != 4 || c.Tiers[2].Color != "#ff00ff" || c.Tiers[0].Color != "#00ff00" || c.Tiers[3].Color != "#000000" {
+		t.Logf("ERROR: expected only the alert tier to be recolored, but got %+v", c.Tiers)
+		t.Fail()
+	}
+	if global.Tiers[2].Color != "#ff0000" {
+		t.Logf("ERROR: expected the global tiers to be left alone, but got %+v", global.Tiers)
+		t.Fail()
+	}
+
+	// and so does a palette
+	c = global.override(Customization{Palette: "colorblind"})
+	if c.Tiers[0].Color != "#56b4e9" || c.Tiers[2].Color != "#d55e00" || c.ClosedColor != "#999" {
+		t.Logf("ERROR: expected the colorblind palette, but got %+v", c)
+		t.Fail()
+	}
+}
+
+func TestCustomizationOverrideThemes(t *testing.T) {
+	global := GetCustomizations()
+	global.Theme = "light"
+	global = global.WithDefaults()
+
+	c := global.override(Customization{Palette: "tol", Theme: "dark"})
+	if c.PassiveColor != "#4477aa" || c.BackgroundColor != "#333" || c.PassiveTime != global.PassiveTime {
+		t.Logf("ERROR: expected the override's palette and theme with the global times, but got %+v", c)
+		t.Fail()
+	}
+	c = global.override(Customization{PassiveTime: 2})
+	if c.Theme != "light" || c.BackgroundColor != "#fafafa" || c.PassiveColor != global.PassiveColor {
+		t.Logf("ERROR: expected the global theme to be inherited, but got %+v", c)
+		t.Fail()
+	}
+}
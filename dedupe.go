@@ -0,0 +1,132 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"sort"
+	"time"
+)
+
+// Gap is a period a pull request spent closed before it was reopened.
+type Gap struct {
+	ClosedAt   time.Time
+	ReopenedAt time.Time
+}
+
+// Deduplicate drops repeated SummarizedPullRequests, keyed on
+// owner/repo/number, keeping the most recently updated copy. This
+// happens when a PR closes or reopens between the open and closed
+// listings being fetched. Since any later PR could replace an earlier
+// one, nothing is passed on until the input is drained.
+func Deduplicate(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		var order []string
+		seen := map[string]SummarizedPullRequest{}
+		for v := range in {
+			key := fmt.Sprintf("%s/%s#%d", v.Owner, v.Repo, v.Number)
+			prev, ok := seen[key]
+			if !ok {
+				order = append(order, key)
+				seen[key] = v
+				continue
+			}
+			// on a tie the closed copy is newer, as closing is the last
+			// thing that can happen to a PR
+			if v.UpdatedAt.After(prev.UpdatedAt) || (v.UpdatedAt.Equal(prev.UpdatedAt) && v.State == "closed") {
+				if len(v.Gaps) < len(prev.Gaps) {
+					v.Gaps = prev.Gaps
+				}
+				seen[key] = v
+			}
+		}
+		for _, key := range order {
+			out <- seen[key]
+		}
+		close(out)
+	}()
+	return out
+}
+
+// TrackReopens looks up the issue events of each pull request and
+// records the periods it spent closed before being reopened, so it
+// can be drawn as one bar with gaps. This costs a request per PR, so
+// it is only used with the REST API; GraphQL fetches the events along
+// with the pull requests. PRs that have never been closed can't have
+// gaps, so aren't looked up. PRs whose events can't be fetched are
+// drawn without gaps, and reported to diag.
+func TrackReopens(ctx context.Context, in <-chan SummarizedPullRequest, client *github.Client, limiter *RateLimiter, diag *Diagnostics) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if !v.WasClosed {
+				out <- v
+				continue
+			}
+			var events []*github.IssueEvent
+			err := limiter.Do(ctx, func() (*github.Response, error) {
+				var resp *github.Response
+				var err error
+				events, resp, err = client.Issues.ListIssueEvents(ctx, v.Owner, v.Repo, v.Number, &github.ListOptions{PerPage: 100})
+				return resp, err
+			})
+			if err != nil {
+				diag.Report("%s/%s#%d: couldn't fetch events: %s", v.Owner, v.Repo, v.Number, err)
+			} else {
+				var changes []stateChange
+				for _, e := range events {
+					if e.Event != nil && e.CreatedAt != nil {
+						changes = append(changes, stateChange{*e.Event, *e.CreatedAt})
+					}
+				}
+				v.Gaps = gaps(changes)
+			}
+			out <- v
+		}
+		close(out)
+	}()
+	return out
+}
+
+// stateChange is a "closed" or "reopened" event on a pull request.
+type stateChange struct {
+	Event string
+	At    time.Time
+}
+
+type byTime []stateChange
+
+func (a byTime) Len() int           { return len(a) }
+func (a byTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTime) Less(i, j int) bool { return a[i].At.Before(a[j].At) }
+
+// gaps pairs each close with the reopen that follows it. A trailing
+// close without a reopen is the PR's actual close, not a gap.
+func gaps(changes []stateChange) []Gap {
+	sort.Sort(byTime(changes))
+	var gs []Gap
+	var closedAt *time.Time
+	for i := range changes {
+		switch changes[i].Event {
+		case "closed":
+			closedAt = &changes[i].At
+		case "reopened":
+			if closedAt != nil {
+				gs = append(gs, Gap{ClosedAt: *closedAt, ReopenedAt: changes[i].At})
+				closedAt = nil
+			}
+		}
+	}
+	return gs
+}
+
+// openDuration is how long a pull request has been open, not counting
+// the time it spent closed before being reopened.
+func openDuration(pr SummarizedPullRequest) time.Duration {
+	d := pr.ClosedAt.Sub(pr.OpenedAt)
+	for _, g := range pr.Gaps {
+		d -= g.ReopenedAt.Sub(g.ClosedAt)
+	}
+	return d
+}
@@ -0,0 +1,153 @@
+package prmonitor
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDeduplicate(t *testing.T) {
+	now := time.Now()
+	in := make(chan SummarizedPullRequest)
+	out := Deduplicate(in)
+	go func() {
+		// listed as open, then closed before the closed listing was fetched
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, State: "open", UpdatedAt: now.Add(-2 * time.Hour)}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 3, State: "open", UpdatedAt: now}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, State: "closed", UpdatedAt: now.Add(-1 * time.Hour)}
+		// an older closed copy of a PR that has since been reopened
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 3, State: "closed", UpdatedAt: now.Add(-3 * time.Hour)}
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "other", Number: 2, State: "open", UpdatedAt: now}
+		close(in)
+	}()
+
+	var prs []SummarizedPullRequest
+	for pr := range out {
+		prs = append(prs, pr)
+	}
+	if len(prs) != 3 {
+		t.Logf("ERROR: expected %d pull requests, but got %d", 3, len(prs))
+		t.Fail()
+		return
+	}
+	if prs[0].Number != 2 || prs[0].State != "closed" {
+		t.Logf("ERROR: expected the newer closed copy of #2, but got %+v", prs[0])
+		t.Fail()
+	}
+	if prs[1].Number != 3 || prs[1].State != "open" {
+		t.Logf("ERROR: expected the newer open copy of #3, but got %+v", prs[1])
+		t.Fail()
+	}
+	if prs[2].Repo != "other" {
+		t.Logf("ERROR: expected PRs from other repos to be kept, but got %+v", prs[2])
+		t.Fail()
+	}
+}
+
+func TestTrackReopens(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/brentdrich/prmonitor/issues/2/events" {
+			t.Logf("ERROR: unexpected request %s", r.URL.Path)
+			t.Fail()
+		}
+		w.Write([]byte(`[
+			{"event": "reopened", "created_at": "2016-10-03T00:00:00Z"},
+			{"event": "closed", "created_at": "2016-10-02T00:00:00Z"},
+			{"event": "labeled", "created_at": "2016-10-04T00:00:00Z"},
+			{"event": "closed", "created_at": "2016-10-05T00:00:00Z"}
+		]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	opened, _ := time.Parse(time.RFC3339, "2016-10-01T00:00:00Z")
+	closed, _ := time.Parse(time.RFC3339, "2016-10-05T00:00:00Z")
+	in := make(chan SummarizedPullRequest, 1)
+	in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, OpenedAt: opened, ClosedAt: closed, WasClosed: true}
+	close(in)
+	pr := <-TrackReopens(context.Background(), in, client, NewRateLimiter(), nil)
+
+	if len(pr.Gaps) != 1 || pr.Gaps[0].ReopenedAt.Sub(pr.Gaps[0].ClosedAt) != 24*time.Hour {
+		t.Logf("ERROR: expected a single day long gap, but got %+v", pr.Gaps)
+		t.Fail()
+	}
+	if openDuration(pr) != 72*time.Hour {
+		t.Logf("ERROR: expected the gap to be left out of the open duration, but got %s", openDuration(pr))
+		t.Fail()
+	}
+}
+
+func TestTrackReopensNeverClosed(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("ERROR: unexpected request %s for a PR that was never closed", r.URL.Path)
+		t.Fail()
+		w.Write([]byte(`[]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan SummarizedPullRequest, 1)
+	in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, State: "open"}
+	close(in)
+	if pr := <-TrackReopens(context.Background(), in, client, NewRateLimiter(), nil); pr.Number != 2 || len(pr.Gaps) != 0 {
+		t.Logf("ERROR: expected the PR to be passed on untouched, but got %+v", pr)
+		t.Fail()
+	}
+}
+
+func TestTrackReopensReopenedPR(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"event": "closed", "created_at": "2016-10-05T00:00:00Z"},
+			{"event": "reopened", "created_at": "2016-10-06T00:00:00Z"}
+		]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	// github keeps the close time of a reopened PR, here 15 days ago
+	now := time.Date(2016, 10, 20, 0, 0, 0, 0, time.UTC)
+	opened := time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC)
+	closed := time.Date(2016, 10, 5, 0, 0, 0, 0, time.UTC)
+	v, err := Transform(&github.PullRequest{
+		Number:    github.Int(2),
+		State:     github.String("open"),
+		CreatedAt: &opened,
+		ClosedAt:  &closed,
+		Base: &github.PullRequestBranch{Repo: &github.Repository{
+			Name:  github.String("prmonitor"),
+			Owner: &github.User{Login: github.String("brentdrich")},
+		}},
+	}, now)
+	if err != nil {
+		panic(err)
+	}
+	in := make(chan SummarizedPullRequest, 1)
+	in <- v
+	close(in)
+	var prs []SummarizedPullRequest
+	for pr := range TrackReopens(context.Background(), FilterByDate(in, now, Config{}), client, NewRateLimiter(), nil) {
+		prs = append(prs, pr)
+	}
+
+	if len(prs) != 1 {
+		t.Logf("ERROR: expected the reopened PR to be kept, but got %d PRs", len(prs))
+		t.Fail()
+		return
+	}
+	if !prs[0].ClosedAt.Equal(now) {
+		t.Logf("ERROR: expected the bar to run until now, but it ends at %s", prs[0].ClosedAt)
+		t.Fail()
+	}
+	if len(prs[0].Gaps) != 1 || !prs[0].Gaps[0].ClosedAt.Equal(closed) {
+		t.Logf("ERROR: expected a gap from the previous close, but got %+v", prs[0].Gaps)
+		t.Fail()
+	}
+}
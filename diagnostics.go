@@ -0,0 +1,36 @@
+package prmonitor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Diagnostics collects problems found while building a dashboard, such
+// as repos that couldn't be fetched or pull requests with malformed
+// data, so they can be shown on the page instead of crashing the
+// handler or silently vanishing. A nil *Diagnostics discards reports.
+type Diagnostics struct {
+	mu       sync.Mutex
+	problems []string
+}
+
+// Report records a problem. It is safe to call from several pipeline
+// stages at once.
+func (d *Diagnostics) Report(format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.problems = append(d.problems, fmt.Sprintf(format, args...))
+}
+
+// Problems returns the problems reported so far.
+func (d *Diagnostics) Problems() []string {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.problems...)
+}
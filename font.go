@@ -0,0 +1,148 @@
+package prmonitor
+
+import (
+	"image"
+	"image/color"
+)
+
+// glyphs is a 5x7 pixel font for the printable ASCII characters,
+// starting at the space, with an eighth row for descenders. Each row is
+// a byte, with the leftmost pixel in bit 4. It lets the PNG chart draw
+// text without font files.
+var glyphs = [95][8]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // space
+	{0x04, 0x04, 0x04, 0x04, 0x00, 0x00, 0x04, 0x00}, // !
+	{0x0A, 0x0A, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00}, // "
+	{0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A, 0x00}, // #
+	{0x04, 0x0F, 0x14, 0x0E, 0x05, 0x1E, 0x04, 0x00}, // $
+	{0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03, 0x00}, // %
+	{0x0C, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0D, 0x00}, // &
+	{0x0C, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}, // '
+	{0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02, 0x00}, // (
+	{0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08, 0x00}, // )
+	{0x00, 0x04, 0x15, 0x0E, 0x15, 0x04, 0x00, 0x00}, // *
+	{0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00, 0x00}, // +
+	{0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08, 0x00}, // ,
+	{0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x00}, // -
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C, 0x00}, // .
+	{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00, 0x00}, // /
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E, 0x00}, // 0
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E, 0x00}, // 1
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F, 0x00}, // 2
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E, 0x00}, // 3
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02, 0x00}, // 4
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E, 0x00}, // 5
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E, 0x00}, // 6
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08, 0x00}, // 7
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E, 0x00}, // 8
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C, 0x00}, // 9
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00, 0x00}, // :
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08, 0x00}, // ;
+	{0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02, 0x00}, // <
+	{0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00, 0x00}, // =
+	{0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08, 0x00}, // >
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04, 0x00}, // ?
+	{0x0E, 0x11, 0x01, 0x0D, 0x15, 0x15, 0x0E, 0x00}, // @
+	{0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x00}, // A
+	{0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E, 0x00}, // B
+	{0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E, 0x00}, // C
+	{0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C, 0x00}, // D
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F, 0x00}, // E
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10, 0x00}, // F
+	{0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F, 0x00}, // G
+	{0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11, 0x00}, // H
+	{0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E, 0x00}, // I
+	{0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C, 0x00}, // J
+	{0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11, 0x00}, // K
+	{0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F, 0x00}, // L
+	{0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11, 0x00}, // M
+	{0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11, 0x00}, // N
+	{0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E, 0x00}, // O
+	{0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10, 0x00}, // P
+	{0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D, 0x00}, // Q
+	{0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11, 0x00}, // R
+	{0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E, 0x00}, // S
+	{0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x00}, // T
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E, 0x00}, // U
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04, 0x00}, // V
+	{0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A, 0x00}, // W
+	{0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11, 0x00}, // X
+	{0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04, 0x00}, // Y
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F, 0x00}, // Z
+	{0x0E, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0E, 0x00}, // [
+	{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00, 0x00}, // backslash
+	{0x0E, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0E, 0x00}, // ]
+	{0x04, 0x0A, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00}, // ^
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x00}, // _
+	{0x08, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00}, // `
+	{0x00, 0x00, 0x0E, 0x01, 0x0F, 0x11, 0x0F, 0x00}, // a
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1E, 0x00}, // b
+	{0x00, 0x00, 0x0E, 0x10, 0x10, 0x11, 0x0E, 0x00}, // c
+	{0x01, 0x01, 0x0D, 0x13, 0x11, 0x11, 0x0F, 0x00}, // d
+	{0x00, 0x00, 0x0E, 0x11, 0x1F, 0x10, 0x0E, 0x00}, // e
+	{0x06, 0x09, 0x08, 0x1C, 0x08, 0x08, 0x08, 0x00}, // f
+	{0x00, 0x00, 0x0F, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // g
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x11, 0x00}, // h
+	{0x04, 0x00, 0x0C, 0x04, 0x04, 0x04, 0x0E, 0x00}, // i
+	{0x02, 0x00, 0x06, 0x02, 0x02, 0x02, 0x12, 0x0C}, // j
+	{0x10, 0x10, 0x12, 0x14, 0x18, 0x14, 0x12, 0x00}, // k
+	{0x0C, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E, 0x00}, // l
+	{0x00, 0x00, 0x1A, 0x15, 0x15, 0x11, 0x11, 0x00}, // m
+	{0x00, 0x00, 0x16, 0x19, 0x11, 0x11, 0x11, 0x00}, // n
+	{0x00, 0x00, 0x0E, 0x11, 0x11, 0x11, 0x0E, 0x00}, // o
+	{0x00, 0x00, 0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10}, // p
+	{0x00, 0x00, 0x0F, 0x11, 0x11, 0x0F, 0x01, 0x01}, // q
+	{0x00, 0x00, 0x16, 0x19, 0x10, 0x10, 0x10, 0x00}, // r
+	{0x00, 0x00, 0x0E, 0x10, 0x0E, 0x01, 0x1E, 0x00}, // s
+	{0x08, 0x08, 0x1C, 0x08, 0x08, 0x09, 0x06, 0x00}, // t
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x0D, 0x00}, // u
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x0A, 0x04, 0x00}, // v
+	{0x00, 0x00, 0x11, 0x11, 0x15, 0x15, 0x0A, 0x00}, // w
+	{0x00, 0x00, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x00}, // x
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // y
+	{0x00, 0x00, 0x1F, 0x02, 0x04, 0x08, 0x1F, 0x00}, // z
+	{0x02, 0x04, 0x04, 0x08, 0x04, 0x04, 0x02, 0x00}, // {
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x00}, // |
+	{0x08, 0x04, 0x04, 0x02, 0x04, 0x04, 0x08, 0x00}, // }
+	{0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00, 0x00}, // ~
+}
+
+// glyph sizes, in pixels before scaling, including the gap to the next
+// character or line.
+const (
+	glyphWidth  = 6
+	glyphHeight = 8
+)
+
+// drawText writes s onto img with its top left corner at x, y, each
+// pixel of the font scaled to a scale by scale square. Characters
+// outside printable ASCII are drawn as "?".
+func drawText(img *image.RGBA, x int, y int, s string, c color.Color, scale int) {
+	for _, r := range s {
+		if r < 32 || r > 126 {
+			r = '?'
+		}
+		for row, bits := range glyphs[r-32] {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>uint(col)) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(x+col*scale+dx, y+row*scale+dy, c)
+					}
+				}
+			}
+		}
+		x += glyphWidth * scale
+	}
+}
+
+// textWidth is how many pixels wide s is when drawn at scale.
+func textWidth(s string, scale int) int {
+	n := 0
+	for range s {
+		n++
+	}
+	return n * glyphWidth * scale
+}
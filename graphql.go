@@ -0,0 +1,368 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"strings"
+	"time"
+)
+
+// graphQLBatchSize is the number of repositories fetched by a single
+// GraphQL query.
+const graphQLBatchSize = 10
+
+// graphQLPullRequestFragment lists the fields fetched for every pull
+// request, including review and status data that would otherwise take
+// several REST calls per pull request.
+const graphQLPullRequestFragment = `
+fragment pr on PullRequest {
+	number
+	title
+	state
+	url
+	createdAt
+	updatedAt
+	closedAt
+	headRefOid
+	additions deletions changedFiles
+	commitCount: commits { totalCount }
+	author { __typename login }
+	labels(first: 20) { nodes { name color } }
+	baseRepository { name owner { login } }
+	reviews(last: 20) { nodes { state author { login } } }
+	reviewRequests(first: 20) { nodes { requestedReviewer { ... on User { login } ... on Team { slug } } } }
+	commits(last: 1) { nodes { commit {
+		oid
+		status { contexts { state createdAt } }
+		checkSuites(first: 20) { nodes { checkRuns(first: 50) { nodes { status conclusion completedAt } } } }
+	} } }
+	timelineItems(itemTypes: [CLOSED_EVENT, REOPENED_EVENT], first: 50) {
+		nodes { __typename ... on ClosedEvent { createdAt } ... on ReopenedEvent { createdAt } }
+	}
+}`
+
+// graphQLRequest is the body posted to the GraphQL endpoint.
+type graphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+// graphQLResponse holds one aliased repository per batched repo.
+type graphQLResponse struct {
+	Data   map[string]*graphQLRepository `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type graphQLRepository struct {
+	Open   graphQLPullRequests `json:"open"`
+	Closed graphQLPullRequests `json:"closed"`
+}
+
+type graphQLPullRequests struct {
+	Nodes []*graphQLPullRequest `json:"nodes"`
+}
+
+type graphQLActor struct {
+	Typename string `json:"__typename"`
+	Login    string `json:"login"`
+}
+
+type graphQLPullRequest struct {
+	Number       int        `json:"number"`
+	Title        string     `json:"title"`
+	State        string     `json:"state"`
+	URL          string     `json:"url"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	ClosedAt     *time.Time `json:"closedAt"`
+	HeadRefOid   string     `json:"headRefOid"`
+	Additions    int        `json:"additions"`
+	Deletions    int        `json:"deletions"`
+	ChangedFiles int        `json:"changedFiles"`
+	CommitCount  struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"commitCount"`
+	Author *graphQLActor `json:"author"`
+	Labels struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+	BaseRepository *struct {
+		Name  string       `json:"name"`
+		Owner graphQLActor `json:"owner"`
+	} `json:"baseRepository"`
+	Reviews struct {
+		Nodes []struct {
+			State  string        `json:"state"`
+			Author *graphQLActor `json:"author"`
+		} `json:"nodes"`
+	} `json:"reviews"`
+	ReviewRequests struct {
+		Nodes []struct {
+			RequestedReviewer *struct {
+				Login string `json:"login"`
+				Slug  string `json:"slug"`
+			} `json:"requestedReviewer"`
+		} `json:"nodes"`
+	} `json:"reviewRequests"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				Oid    string `json:"oid"`
+				Status *struct {
+					Contexts []struct {
+						State     string    `json:"state"`
+						CreatedAt time.Time `json:"createdAt"`
+					} `json:"contexts"`
+				} `json:"status"`
+				CheckSuites struct {
+					Nodes []struct {
+						CheckRuns struct {
+							Nodes []struct {
+								Status      string     `json:"status"`
+								Conclusion  string     `json:"conclusion"`
+								CompletedAt *time.Time `json:"completedAt"`
+							} `json:"nodes"`
+						} `json:"checkRuns"`
+					} `json:"nodes"`
+				} `json:"checkSuites"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+	TimelineItems struct {
+		Nodes []struct {
+			Typename  string    `json:"__typename"`
+			CreatedAt time.Time `json:"createdAt"`
+		} `json:"nodes"`
+	} `json:"timelineItems"`
+}
+
+// RetrieveGraphQL is an alternative to Retrieve that uses the github
+// GraphQL API. Repositories are batched so that open and recently
+// closed pull requests for many repos are fetched in a single query.
+// The query is sent through client, so it shares authentication and
+// transport with the REST API and can be pointed at a fake server by
+// changing client.BaseURL. Queries are paced and retried by limiter,
+// and given the longest timeout of the repos in the batch. Failed
+// batches and malformed pull requests are reported to diag.
+func RetrieveGraphQL(ctx context.Context, in chan Repo, client *github.Client, limiter *RateLimiter, diag *Diagnostics, now time.Time) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		var batch []Repo
+		flush := func() {
+			// the batch waits as long as its most patient repo
+			longest := Repo{}
+			for i, r := range batch {
+				if r.Timeout == 0 {
+					longest.Timeout = 0
+					break
+				}
+				if i == 0 || r.Timeout > longest.Timeout {
+					longest.Timeout = r.Timeout
+				}
+			}
+			bctx, cancel := repoContext(ctx, longest)
+			prs, err := queryPullRequests(bctx, client, limiter, diag, batch)
+			cancel()
+			if err != nil {
+				diag.Report("couldn't query pull requests for %d repos: %s", len(batch), err)
+			}
+			batch = nil
+			for _, v := range prs {
+				p, err := transformGraphQL(v, now)
+				if err != nil {
+					diag.Report("dropped %s", err)
+					continue
+				}
+				out <- p
+			}
+		}
+		for r := range in {
+			batch = append(batch, r)
+			if len(batch) == graphQLBatchSize {
+				flush()
+			}
+		}
+		if len(batch) > 0 {
+			flush()
+		}
+		close(out)
+	}()
+	return out
+}
+
+// queryPullRequests fetches the open and recently closed pull requests
+// of every repo in a single GraphQL query.
+func queryPullRequests(ctx context.Context, client *github.Client, limiter *RateLimiter, diag *Diagnostics, repos []Repo) ([]*graphQLPullRequest, error) {
+	q, vars := graphQLQuery(repos)
+	var resp graphQLResponse
+	err := limiter.Do(ctx, func() (*github.Response, error) {
+		// the request body can only be read once, so build it per attempt
+		req, err := client.NewRequest("POST", "graphql", graphQLRequest{Query: q, Variables: vars})
+		if err != nil {
+			return nil, err
+		}
+		return client.Do(ctx, req, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data == nil && len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql: %s", resp.Errors[0].Message)
+	}
+
+	var prs []*graphQLPullRequest
+	for i := range repos {
+		// repos that can't be found come back as null alongside an error
+		r := resp.Data[fmt.Sprintf("r%d", i)]
+		if r == nil {
+			diag.Report("%s/%s: not found", repos[i].Owner, repos[i].Repo)
+			continue
+		}
+		prs = append(prs, r.Open.Nodes...)
+		prs = append(prs, r.Closed.Nodes...)
+	}
+	return prs, nil
+}
+
+// graphQLQuery builds a query with one aliased repository per repo,
+// along with the variables it references.
+func graphQLQuery(repos []Repo) (string, map[string]string) {
+	var params, body []string
+	vars := map[string]string{}
+	for i, r := range repos {
+		depth := r.Depth
+		if depth <= 0 {
+			depth = 30
+		} else if depth > 100 {
+			depth = 100
+		}
+		params = append(params, fmt.Sprintf("$owner%d: String!, $name%d: String!", i, i))
+		body = append(body, fmt.Sprintf(`r%d: repository(owner: $owner%d, name: $name%d) {
+	open: pullRequests(states: OPEN, baseRefName: "master", first: %d, orderBy: {field: CREATED_AT, direction: DESC}) { nodes { ...pr } }
+	closed: pullRequests(states: [CLOSED, MERGED], baseRefName: "master", first: %d, orderBy: {field: UPDATED_AT, direction: DESC}) { nodes { ...pr } }
+}`, i, i, i, depth, depth))
+		vars[fmt.Sprintf("owner%d", i)] = r.Owner
+		vars[fmt.Sprintf("name%d", i)] = r.Repo
+	}
+	q := fmt.Sprintf("query(%s) {\n%s\n}\n%s", strings.Join(params, ", "), strings.Join(body, "\n"), graphQLPullRequestFragment)
+	return q, vars
+}
+
+// transformGraphQL converts a GraphQL pull request into the same
+// summary that Transform produces for the REST API.
+func transformGraphQL(v *graphQLPullRequest, now time.Time) (SummarizedPullRequest, error) {
+	if v == nil {
+		return SummarizedPullRequest{}, fmt.Errorf("empty pull request")
+	}
+	if v.BaseRepository == nil {
+		return SummarizedPullRequest{}, fmt.Errorf("pull request #%d has no base repository", v.Number)
+	}
+	// deleted users come back as a null author
+	author := GhostUser
+	if v.Author != nil {
+		author = v.Author.Login
+		// match the login the REST API reports for apps
+		if v.Author.Typename == "Bot" {
+			author += "[bot]"
+		}
+	}
+	state := "closed"
+	if v.State == "OPEN" {
+		state = "open"
+	}
+	// reopened PRs keep their last close time
+	closedAt := now
+	if v.ClosedAt != nil && state == "closed" {
+		closedAt = *v.ClosedAt
+	}
+	var reviewers, teams []string
+	for _, n := range v.ReviewRequests.Nodes {
+		switch {
+		case n.RequestedReviewer == nil:
+		case n.RequestedReviewer.Slug != "":
+			teams = append(teams, n.RequestedReviewer.Slug)
+		case n.RequestedReviewer.Login != "":
+			reviewers = append(reviewers, n.RequestedReviewer.Login)
+		}
+	}
+	// a reviewer's latest approval or request for changes stands
+	// until they are asked to review again
+	latest := map[string]string{}
+	for _, r := range v.Reviews.Nodes {
+		if r.Author != nil && (r.State == "APPROVED" || r.State == "CHANGES_REQUESTED" || r.State == "DISMISSED") {
+			latest[r.Author.Login] = r.State
+		}
+	}
+	reviewState := "review_required"
+	if len(reviewers) == 0 && len(teams) == 0 {
+		for _, s := range latest {
+			if s == "CHANGES_REQUESTED" {
+				reviewState = "changes_requested"
+				break
+			}
+			if s == "APPROVED" {
+				reviewState = "approved"
+			}
+		}
+	}
+	// like TrackCIStatus, only look at the builds of open PRs, and at
+	// check runs rather than suites, since github leaves suites queued
+	// forever for apps that never run anything
+	var ci ciResult
+	for _, c := range v.Commits.Nodes {
+		if state != "open" {
+			break
+		}
+		if c.Commit.Status != nil {
+			for _, s := range c.Commit.Status.Contexts {
+				ci.add("", s.State, s.CreatedAt)
+			}
+		}
+		for _, suite := range c.Commit.CheckSuites.Nodes {
+			for _, r := range suite.CheckRuns.Nodes {
+				var at time.Time
+				if r.CompletedAt != nil {
+					at = *r.CompletedAt
+				}
+				ci.add(r.Status, r.Conclusion, at)
+			}
+		}
+	}
+	var changes []stateChange
+	for _, e := range v.TimelineItems.Nodes {
+		switch e.Typename {
+		case "ClosedEvent":
+			changes = append(changes, stateChange{"closed", e.CreatedAt})
+		case "ReopenedEvent":
+			changes = append(changes, stateChange{"reopened", e.CreatedAt})
+		}
+	}
+	return SummarizedPullRequest{
+		Owner:     v.BaseRepository.Owner.Login,
+		Repo:      v.BaseRepository.Name,
+		Number:    v.Number,
+		Title:     v.Title,
+		URL:       v.URL,
+		Author:    author,
+		OpenedAt:  v.CreatedAt,
+		ClosedAt:  closedAt,
+		UpdatedAt: v.UpdatedAt,
+		Gaps:      gaps(changes),
+		Labels:    v.Labels.Nodes,
+		HeadSHA:   v.HeadRefOid,
+
+		RequestedReviewers: reviewers,
+		RequestedTeams:     teams,
+		ReviewState:        reviewState,
+		CIStatus:           ci.state(),
+		CIFailedAt:         ci.failedAt,
+		Additions:          v.Additions,
+		Deletions:          v.Deletions,
+		ChangedFiles:       v.ChangedFiles,
+		Commits:            v.CommitCount.TotalCount,
+		State:              state,
+	}, nil
+}
@@ -0,0 +1,223 @@
+package prmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGraphQL starts a server that answers every batched query with
+// one open and one closed pull request per aliased repository.
+func fakeGraphQL(t *testing.T, queries *int) (*httptest.Server, *github.Client) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql" {
+			t.Logf("ERROR: unexpected request %s %s", r.Method, r.URL.Path)
+			t.Fail()
+			w.WriteHeader(404)
+			return
+		}
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Logf("ERROR: couldn't decode query: %s", err)
+			t.Fail()
+			return
+		}
+		*queries++
+
+		data := map[string]interface{}{}
+		for i := 0; req.Variables[fmt.Sprintf("owner%d", i)] != ""; i++ {
+			owner := req.Variables[fmt.Sprintf("owner%d", i)]
+			name := req.Variables[fmt.Sprintf("name%d", i)]
+			base := map[string]interface{}{"name": name, "owner": map[string]string{"login": owner}}
+			data[fmt.Sprintf("r%d", i)] = map[string]interface{}{
+				"open": map[string]interface{}{"nodes": []interface{}{
+					map[string]interface{}{
+						"number": 2, "title": "open pr", "state": "OPEN",
+						"createdAt": "2016-10-01T00:00:00Z", "updatedAt": "2016-10-02T00:00:00Z",
+						"author": map[string]string{"login": "brentdrich"}, "baseRepository": base,
+					},
+				}},
+				"closed": map[string]interface{}{"nodes": []interface{}{
+					map[string]interface{}{
+						"number": 1, "title": "merged pr", "state": "MERGED",
+						"createdAt": "2016-09-28T00:00:00Z", "updatedAt": "2016-09-30T00:00:00Z",
+						"closedAt": "2016-09-30T00:00:00Z",
+						"author":   map[string]string{"login": "brentdrich"}, "baseRepository": base,
+					},
+				}},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return s, client
+}
+
+func TestRetrieveGraphQL(t *testing.T) {
+	queries := 0
+	s, client := fakeGraphQL(t, &queries)
+	defer s.Close()
+
+	now, _ := time.Parse(time.RFC3339, "2016-10-03T00:00:00Z")
+	in := make(chan Repo)
+	out := RetrieveGraphQL(context.Background(), in, client, NewRateLimiter(), nil, now)
+	go func() {
+		for i := 0; i < graphQLBatchSize+2; i++ {
+			in <- Repo{Owner: "brentdrich", Repo: fmt.Sprintf("repo%d", i), Depth: 15}
+		}
+		close(in)
+	}()
+
+	var prs []SummarizedPullRequest
+	for pr := range out {
+		prs = append(prs, pr)
+	}
+
+	if queries != 2 {
+		t.Logf("ERROR: expected %d batched queries, but got %d", 2, queries)
+		t.Fail()
+	}
+	if len(prs) != 2*(graphQLBatchSize+2) {
+		t.Logf("ERROR: expected %d pull requests, but got %d", 2*(graphQLBatchSize+2), len(prs))
+		t.Fail()
+		return
+	}
+
+	open, closed := prs[0], prs[1]
+	if open.State != "open" || !open.ClosedAt.Equal(now) || open.Repo != "repo0" || open.Author != "brentdrich" {
+		t.Logf("ERROR: unexpected open pull request %+v", open)
+		t.Fail()
+	}
+	if closed.State != "closed" || closed.ClosedAt.Equal(now) || closed.Number != 1 {
+		t.Logf("ERROR: unexpected closed pull request %+v", closed)
+		t.Fail()
+	}
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	q, vars := graphQLQuery([]Repo{
+		{Owner: "brentdrich", Repo: "prmonitor", Depth: 15},
+		{Owner: "docker", Repo: "swarmkit"},
+	})
+
+	for _, want := range []string{
+		"r0: repository(owner: $owner0, name: $name0)",
+		"r1: repository(owner: $owner1, name: $name1)",
+		"first: 15",
+		"first: 30",
+		"fragment pr on PullRequest",
+	} {
+		if !strings.Contains(q, want) {
+			t.Logf("ERROR: expected query to contain '%s'", want)
+			t.Fail()
+		}
+	}
+	if vars["owner1"] != "docker" || vars["name1"] != "swarmkit" {
+		t.Logf("ERROR: unexpected variables %v", vars)
+		t.Fail()
+	}
+}
+
+func TestGraphQLReviewState(t *testing.T) {
+	tests := []struct {
+		pr       string
+		expected string
+	}{
+		{`{"reviews": {"nodes": []}}`, "review_required"},
+		{`{"reviews": {"nodes": [{"state": "APPROVED", "author": {"login": "a"}}]}}`, "approved"},
+		{`{"reviews": {"nodes": [{"state": "APPROVED", "author": {"login": "a"}}, {"state": "CHANGES_REQUESTED", "author": {"login": "b"}}]}}`, "changes_requested"},
+		{`{"reviews": {"nodes": [{"state": "CHANGES_REQUESTED", "author": {"login": "b"}}, {"state": "APPROVED", "author": {"login": "b"}}]}}`, "approved"},
+		{`{"reviews": {"nodes": [{"state": "CHANGES_REQUESTED", "author": {"login": "b"}}]},
+		   "reviewRequests": {"nodes": [{"requestedReviewer": {"login": "b"}}]}}`, "review_required"},
+	}
+	for _, test := range tests {
+		var v graphQLPullRequest
+		if err := json.Unmarshal([]byte(test.pr), &v); err != nil {
+			panic(err)
+		}
+		v.Number = 1
+		v.State = "OPEN"
+		v.BaseRepository = &struct {
+			Name  string       `json:"name"`
+			Owner graphQLActor `json:"owner"`
+		}{Name: "prmonitor", Owner: graphQLActor{Login: "brentdrich"}}
+		pr, err := transformGraphQL(&v, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		if pr.ReviewState != test.expected {
+			t.Logf("ERROR: %s: expected %q, but got %q", test.pr, test.expected, pr.ReviewState)
+			t.Fail()
+		}
+	}
+}
+
+func TestGraphQLReopened(t *testing.T) {
+	now := time.Date(2016, 10, 20, 0, 0, 0, 0, time.UTC)
+	closed := time.Date(2016, 10, 5, 0, 0, 0, 0, time.UTC)
+	for _, state := range []string{"OPEN", "CLOSED"} {
+		v := graphQLPullRequest{Number: 1, State: state, ClosedAt: &closed}
+		v.BaseRepository = &struct {
+			Name  string       `json:"name"`
+			Owner graphQLActor `json:"owner"`
+		}{Name: "prmonitor", Owner: graphQLActor{Login: "brentdrich"}}
+		pr, err := transformGraphQL(&v, now)
+		if err != nil {
+			panic(err)
+		}
+		expected := closed
+		if state == "OPEN" {
+			expected = now
+		}
+		if !pr.ClosedAt.Equal(expected) {
+			t.Logf("ERROR: %s: expected the bar to end at %s, but got %s", state, expected, pr.ClosedAt)
+			t.Fail()
+		}
+	}
+}
+
+func TestGraphQLCIStatus(t *testing.T) {
+	const commits = `{"commits": {"nodes": [{"commit": {"checkSuites": {"nodes": [
+		{"checkRuns": {"nodes": []}},
+		{"checkRuns": {"nodes": [
+			{"status": "COMPLETED", "conclusion": "FAILURE", "completedAt": "2018-02-03T00:00:00Z"},
+			{"status": "COMPLETED", "conclusion": "FAILURE", "completedAt": "2018-02-02T00:00:00Z"}
+		]}}
+	]}}}]}}`
+	for _, state := range []string{"OPEN", "CLOSED"} {
+		var v graphQLPullRequest
+		if err := json.Unmarshal([]byte(commits), &v); err != nil {
+			panic(err)
+		}
+		v.Number = 1
+		v.State = state
+		v.BaseRepository = &struct {
+			Name  string       `json:"name"`
+			Owner graphQLActor `json:"owner"`
+		}{Name: "prmonitor", Owner: graphQLActor{Login: "brentdrich"}}
+		pr, err := transformGraphQL(&v, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		expected := ""
+		if state == "OPEN" {
+			expected = "failure"
+		}
+		if pr.CIStatus != expected {
+			t.Logf("ERROR: %s: expected status %q, but got %q", state, expected, pr.CIStatus)
+			t.Fail()
+		}
+		if state == "OPEN" && !pr.CIFailedAt.Equal(time.Date(2018, 2, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Logf("ERROR: expected the time of the first failure, but got %s", pr.CIFailedAt)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package prmonitor
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// prGroup is a swimlane of pull requests on the dashboard.
+type prGroup struct {
+	Name string
+	PRs  SummarizedPullRequests
+}
+
+// groupPRs splits prs into swimlanes by config.GroupBy: "repo",
+// "author", "team" (the author's team in config.Teams) or "label". PRs
+// with several labels or teams appear in each of their groups, and
+// PRs in none go in an "other" group at the end. The order of prs is
+// kept within each group. Without GroupBy, everything is in a single
+// unnamed group.
+func groupPRs(prs SummarizedPullRequests, config Config) []prGroup {
+	if config.GroupBy == "" {
+		return []prGroup{{PRs: prs}}
+	}
+	byName := map[string]*prGroup{}
+	var names []string
+	for _, pr := range prs {
+		keys := groupKeys(pr, config)
+		if len(keys) == 0 {
+			keys = []string{"other"}
+		}
+		for _, k := range keys {
+			g, ok := byName[k]
+			if !ok {
+				g = &prGroup{Name: k}
+				byName[k] = g
+				names = append(names, k)
+			}
+			g.PRs = append(g.PRs, pr)
+		}
+	}
+	sort.Sort(groupNames(names))
+	var groups []prGroup
+	for _, n := range names {
+		groups = append(groups, *byName[n])
+	}
+	return groups
+}
+
+// groupKeys returns the names of the groups pr belongs in.
+func groupKeys(pr SummarizedPullRequest, config Config) []string {
+	switch config.GroupBy {
+	case "repo":
+		return []string{pr.Owner + "/" + pr.Repo}
+	case "author":
+		return []string{pr.Author}
+	case "team":
+		var teams []string
+		for team, members := range config.Teams {
+			for _, m := range members {
+				if strings.EqualFold(m, pr.Author) {
+					teams = append(teams, team)
+					break
+				}
+			}
+		}
+		return teams
+	case "label":
+		var labels []string
+		for _, l := range pr.Labels {
+			labels = append(labels, l.Name)
+		}
+		return labels
+	}
+	return nil
+}
+
+// groupNames sorts group names alphabetically, with "other" last.
+type groupNames []string
+
+func (a groupNames) Len() int      { return len(a) }
+func (a groupNames) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a groupNames) Less(i, j int) bool {
+	if a[i] == "other" || a[j] == "other" {
+		return a[j] == "other" && a[i] != "other"
+	}
+	return strings.ToLower(a[i]) < strings.ToLower(a[j])
+}
+
+// groupSummary returns how many of a group's PRs are open, and the
+// median time they have been waiting.
+func groupSummary(g prGroup, config Config) (int, time.Duration) {
+	var ages []float64
+	for _, pr := range g.PRs {
+		if pr.State == "open" {
+			ages = append(ages, float64(reviewDuration(pr, config)))
+		}
+	}
+	return len(ages), time.Duration(median(ages))
+}
+
+// median returns the middle of values, or zero if there are none.
+// values is sorted in place.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	if len(values)%2 == 0 {
+		return (values[len(values)/2-1] + values[len(values)/2]) / 2
+	}
+	return values[len(values)/2]
+}
@@ -0,0 +1,52 @@
+package prmonitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGroupPRs(t *testing.T) {
+	prs := SummarizedPullRequests{
+		{Repo: "prmonitor", Number: 1, Author: "brentdrich", Labels: []Label{{Name: "bug"}, {Name: "api"}}},
+		{Repo: "prmonitor", Number: 2, Author: "someone", Labels: []Label{{Name: "bug"}}},
+		{Repo: "prmonitor", Number: 3, Author: "BrentDRich"},
+	}
+	config := Config{Teams: map[string][]string{"core": {"brentdrich"}, "web": {"brentdrich", "someone"}}}
+	tests := []struct {
+		groupBy  string
+		expected string
+	}{
+		{"", "[: 1 2 3]"},
+		{"label", "[api: 1 bug: 1 2 other: 3]"},
+		{"team", "[core: 1 3 web: 1 2 3]"},
+		{"author", "[brentdrich: 1 BrentDRich: 3 someone: 2]"},
+	}
+	for _, test := range tests {
+		config.GroupBy = test.groupBy
+		var got []string
+		for _, g := range groupPRs(prs, config) {
+			s := g.Name + ":"
+			for _, pr := range g.PRs {
+				s += fmt.Sprintf(" %d", pr.Number)
+			}
+			got = append(got, s)
+		}
+		if fmt.Sprint(got) != test.expected {
+			t.Logf("ERROR: grouping by %q: expected %s, but got %v", test.groupBy, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestGroupSummary(t *testing.T) {
+	now := time.Now()
+	pr := func(hours int, state string) SummarizedPullRequest {
+		return SummarizedPullRequest{OpenedAt: now.Add(-time.Duration(hours) * time.Hour), ClosedAt: now, State: state}
+	}
+	open, age := groupSummary(prGroup{PRs: SummarizedPullRequests{pr(1, "open"), pr(5, "open"), pr(100, "closed"), pr(9, "open"), pr(20, "open")}}, Config{})
+	if open != 4 || age != 7*time.Hour {
+		t.Logf("ERROR: expected 4 open with a median age of 7h, but got %d and %s", open, age)
+		t.Fail()
+	}
+}
@@ -0,0 +1,74 @@
+package prmonitor
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Label is a github label attached to a pull request.
+type Label struct {
+	Name string
+
+	// the hex color github shows the label in, without a leading #.
+	Color string
+}
+
+// FilterByLabel drops SummarizedPullRequests that don't carry any of the
+// include labels (provided the list isn't empty), or that carry any of
+// the exclude labels. Labels are matched case-insensitively, like
+// github does.
+func FilterByLabel(in <-chan SummarizedPullRequest, include []string, exclude []string) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if len(include) > 0 && !hasLabel(v, include) {
+				continue
+			}
+			if hasLabel(v, exclude) {
+				continue
+			}
+			out <- v
+		}
+		close(out)
+	}()
+	return out
+}
+
+// hasLabel reports whether the pull request has any of the labels.
+func hasLabel(pr SummarizedPullRequest, labels []string) bool {
+	for _, l := range pr.Labels {
+		for _, name := range labels {
+			if strings.EqualFold(l.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// labelChips renders a pull request's labels as small chips in their
+// github colors.
+func labelChips(pr SummarizedPullRequest) string {
+	var chips []string
+	for _, l := range pr.Labels {
+		chips = append(chips, fmt.Sprintf("<span style='background: #%s; color: %s; border-radius: 0.8em; padding: 0 0.5em; margin-left: 0.4em; font-size: small;'>%s</span>",
+			html.EscapeString(l.Color), labelTextColor(l.Color), html.EscapeString(l.Name)))
+	}
+	return strings.Join(chips, "")
+}
+
+// labelTextColor picks black or white text, whichever reads better on
+// the label color.
+func labelTextColor(color string) string {
+	c, err := strconv.ParseUint(color, 16, 32)
+	if err != nil || len(color) != 6 {
+		return "#000"
+	}
+	r, g, b := float64(c>>16&0xff), float64(c>>8&0xff), float64(c&0xff)
+	if 0.299*r+0.587*g+0.114*b > 150 {
+		return "#000"
+	}
+	return "#fff"
+}
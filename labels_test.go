@@ -0,0 +1,62 @@
+package prmonitor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func filterLabels(prs []SummarizedPullRequest, include []string, exclude []string) []int {
+	in := make(chan SummarizedPullRequest)
+	out := FilterByLabel(in, include, exclude)
+	go func() {
+		for _, pr := range prs {
+			in <- pr
+		}
+		close(in)
+	}()
+	var numbers []int
+	for pr := range out {
+		numbers = append(numbers, pr.Number)
+	}
+	return numbers
+}
+
+func TestFilterByLabel(t *testing.T) {
+	prs := []SummarizedPullRequest{
+		{Number: 1, Labels: []Label{{Name: "team-payments"}}},
+		{Number: 2, Labels: []Label{{Name: "Team-Payments"}, {Name: "wip"}}},
+		{Number: 3, Labels: []Label{{Name: "dependencies"}}},
+		{Number: 4},
+	}
+
+	tests := []struct {
+		include, exclude []string
+		expected         string
+	}{
+		{nil, nil, "[1 2 3 4]"},
+		{nil, []string{"wip", "dependencies"}, "[1 4]"},
+		{[]string{"team-payments"}, nil, "[1 2]"},
+		{[]string{"team-payments"}, []string{"wip"}, "[1]"},
+	}
+	for _, test := range tests {
+		got := fmt.Sprint(filterLabels(prs, test.include, test.exclude))
+		if got != test.expected {
+			t.Logf("ERROR: include %v exclude %v: expected %s, but got %s", test.include, test.exclude, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestLabelChips(t *testing.T) {
+	chips := labelChips(SummarizedPullRequest{Labels: []Label{
+		{Name: "bug", Color: "ee0701"},
+		{Name: "<wip>", Color: "fef2c0"},
+	}})
+	for _, want := range []string{"background: #ee0701; color: #fff", "background: #fef2c0; color: #000", "&lt;wip&gt;"} {
+		if !strings.Contains(chips, want) {
+			t.Logf("ERROR: expected chips to contain '%s', but got %s", want, chips)
+			t.Fail()
+		}
+	}
+}
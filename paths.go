@@ -0,0 +1,142 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"path"
+	"strings"
+	"sync"
+)
+
+// changedFilesLimit is how many head commits' files a ChangedFiles
+// remembers.
+const changedFilesLimit = 5000
+
+// ChangedFiles looks up the files a pull request touches. Results are
+// cached by head commit, so a PR is only fetched again once it has new
+// commits pushed to it. Only the most recently used commits are kept.
+type ChangedFiles struct {
+	client  *github.Client
+	limiter *RateLimiter
+
+	mu    sync.Mutex
+	files *lru
+}
+
+// NewChangedFiles creates an empty ChangedFiles cache.
+func NewChangedFiles(client *github.Client, limiter *RateLimiter) *ChangedFiles {
+	return &ChangedFiles{client: client, limiter: limiter, files: newLRU(changedFilesLimit)}
+}
+
+// List returns the paths of the files changed by pr.
+func (c *ChangedFiles) List(ctx context.Context, pr SummarizedPullRequest) ([]string, error) {
+	key := fmt.Sprintf("%s/%s@%s", pr.Owner, pr.Repo, pr.HeadSHA)
+	if pr.HeadSHA != "" {
+		c.mu.Lock()
+		files, ok := c.files.get(key)
+		c.mu.Unlock()
+		if ok {
+			return files.([]string), nil
+		}
+	}
+
+	var files []string
+	op := &github.ListOptions{PerPage: 100}
+	for {
+		var page []*github.CommitFile
+		var resp *github.Response
+		err := c.limiter.Do(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = c.client.PullRequests.ListFiles(ctx, pr.Owner, pr.Repo, pr.Number, op)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range page {
+			files = append(files, f.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		op.Page = resp.NextPage
+	}
+
+	if pr.HeadSHA != "" {
+		c.mu.Lock()
+		c.files.add(key, files, 1)
+		c.mu.Unlock()
+	}
+	return files, nil
+}
+
+// FilterByPath drops SummarizedPullRequests that don't change any files
+// under their repo's IncludePaths (if the repo has them), ignoring files
+// under its ExcludePaths. Repos without paths are passed through without
+// fetching anything. PRs whose files can't be fetched are kept and
+// reported to diag.
+func FilterByPath(ctx context.Context, in <-chan SummarizedPullRequest, repos []Repo, files *ChangedFiles, diag *Diagnostics) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			r, ok := findRepo(repos, v.Owner, v.Repo)
+			if !ok || (len(r.IncludePaths) == 0 && len(r.ExcludePaths) == 0) {
+				out <- v
+				continue
+			}
+			changed, err := files.List(ctx, v)
+			if err != nil {
+				diag.Report("%s/%s#%d: couldn't list changed files: %s", v.Owner, v.Repo, v.Number, err)
+				out <- v
+				continue
+			}
+			if touchesPaths(changed, r.IncludePaths, r.ExcludePaths) {
+				out <- v
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// findRepo looks up the configuration of a repository by name.
+func findRepo(repos []Repo, owner string, repo string) (Repo, bool) {
+	for _, r := range repos {
+		if strings.EqualFold(r.Owner, owner) && strings.EqualFold(r.Repo, repo) {
+			return r, true
+		}
+	}
+	return Repo{}, false
+}
+
+// touchesPaths reports whether any of the files is under one of the
+// include paths (or anywhere, if there are none) and not under one of
+// the exclude paths.
+func touchesPaths(files []string, include []string, exclude []string) bool {
+	for _, f := range files {
+		if len(include) > 0 && !matchesAny(include, f) {
+			continue
+		}
+		if matchesAny(exclude, f) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// matchesAny reports whether file is under any of the directories or
+// matches any of the glob patterns.
+func matchesAny(patterns []string, file string) bool {
+	for _, p := range patterns {
+		dir := strings.TrimSuffix(p, "/")
+		if file == dir || strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+	}
+	return false
+}
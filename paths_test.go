@@ -0,0 +1,107 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestTouchesPaths(t *testing.T) {
+	tests := []struct {
+		files            []string
+		include, exclude []string
+		expected         bool
+	}{
+		{[]string{"services/payments/charge.go"}, []string{"services/payments"}, nil, true},
+		{[]string{"services/payments/charge.go"}, []string{"services/payments/"}, nil, true},
+		{[]string{"services/paymentsv2/charge.go"}, []string{"services/payments"}, nil, false},
+		{[]string{"docs/README.md"}, []string{"*/*.md"}, nil, true},
+		{[]string{"services/payments/charge_test.go"}, []string{"services/payments"}, []string{"services/payments/*_test.go"}, false},
+		{[]string{"services/payments/charge_test.go", "services/payments/charge.go"}, []string{"services/payments"}, []string{"services/payments/*_test.go"}, true},
+		{[]string{"vendor/lib.go"}, nil, []string{"vendor"}, false},
+	}
+	for _, test := range tests {
+		if got := touchesPaths(test.files, test.include, test.exclude); got != test.expected {
+			t.Logf("ERROR: files %v include %v exclude %v: expected %t, but got %t", test.files, test.include, test.exclude, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestFilterByPath(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/repos/brentdrich/monorepo/pulls/1/files":
+			w.Write([]byte(`[{"filename": "services/payments/charge.go"}]`))
+		case "/repos/brentdrich/monorepo/pulls/2/files":
+			w.Write([]byte(`[{"filename": "services/search/index.go"}]`))
+		default:
+			t.Logf("ERROR: unexpected request %s", r.URL.Path)
+			t.Fail()
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	repos := []Repo{
+		{Owner: "brentdrich", Repo: "monorepo", IncludePaths: []string{"services/payments"}},
+		{Owner: "brentdrich", Repo: "prmonitor"},
+	}
+	files := NewChangedFiles(client, NewRateLimiter())
+	filter := func() string {
+		in := make(chan SummarizedPullRequest)
+		out := FilterByPath(context.Background(), in, repos, files, nil)
+		go func() {
+			in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "monorepo", Number: 1, HeadSHA: "abc"}
+			in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "monorepo", Number: 2, HeadSHA: "def"}
+			in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 3, HeadSHA: "123"}
+			close(in)
+		}()
+		var kept []string
+		for pr := range out {
+			kept = append(kept, fmt.Sprintf("%s#%d", pr.Repo, pr.Number))
+		}
+		return fmt.Sprint(kept)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := filter(); got != "[monorepo#1 prmonitor#3]" {
+			t.Logf("ERROR: expected [monorepo#1 prmonitor#3], but got %s", got)
+			t.Fail()
+		}
+	}
+	if requests != 2 {
+		t.Logf("ERROR: expected files to be cached by head sha, but got %d requests", requests)
+		t.Fail()
+	}
+}
+
+func TestChangedFilesEviction(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[{"filename": "README.md"}]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	files := NewChangedFiles(client, NewRateLimiter())
+	files.files = newLRU(1)
+	for _, sha := range []string{"abc", "abc", "def", "abc"} {
+		if _, err := files.List(context.Background(), SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 1, HeadSHA: sha}); err != nil {
+			panic(err)
+		}
+	}
+	if requests != 3 {
+		t.Logf("ERROR: expected only the latest commit to stay cached, but got %d requests", requests)
+		t.Fail()
+	}
+}
@@ -0,0 +1,208 @@
+package prmonitor
+
+import (
+	"fmt"
+	"github.com/google/go-github/github"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// limits on the size of a PNG chart, in pixels.
+const (
+	pngDefaultWidth = 1000
+	pngMinWidth     = 200
+	pngMaxWidth     = 4000
+	pngMinHeight    = 100
+	pngMaxHeight    = 4000
+)
+
+// ChartPNG responds to an http request with the dashboard drawn as a
+// PNG image, for attaching to chat messages and emails. It accepts the
+// same query parameters as the dashboard, plus width and height in
+// pixels. Without a height, the image is as tall as its rows need, up
+// to the largest height allowed.
+func ChartPNG(t Config, client *github.Client) http.HandlerFunc {
+	d := newDashboard(client)
+	return func(w http.ResponseWriter, r *http.Request) {
+		width, err := pngSize(r.URL.Query().Get("width"), pngDefaultWidth, pngMinWidth, pngMaxWidth)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid width: %s", err), http.StatusBadRequest)
+			return
+		}
+		height, err := pngSize(r.URL.Query().Get("height"), 0, pngMinHeight, pngMaxHeight)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid height: %s", err), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		d.serve(w, r, t, func(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics) <-chan bool {
+			return PNG(in, w, now, sortBy, config, limiter, diag, width, height)
+		})
+	}
+}
+
+// pngSize reads a size query parameter, which must be between min and
+// max pixels.
+func pngSize(s string, def int, min int, max int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%q is not a number of pixels from %d to %d", s, min, max)
+	}
+	return n, nil
+}
+
+// PNG draws pull requests as a Gantt chart in a PNG image, once they
+// have all come in from the rest of the pipeline. It takes the same
+// arguments as Display, and the size of the image: a height of 0 fits
+// the image to its rows, as long as that isn't taller than
+// pngMaxHeight. Otherwise rows are squeezed or stretched to fill it,
+// and their labels left off if they get too thin to read.
+func PNG(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics, width int, height int) <-chan bool {
+	out := make(chan bool)
+	go func() {
+		config.Customization = config.Customization.WithDefaults()
+		theme := config.Customization
+		axis := newChartAxis(now, config)
+		var prs SummarizedPullRequests
+		for pr := range in {
+			prs = append(prs, pr)
+		}
+		sortPRs(prs, sortBy, diag)
+		groups := groupPRs(prs, config)
+
+		// text is drawn twice size on charts wide enough for it
+		scale := 1
+		if width >= 800 {
+			scale = 2
+		}
+		line := glyphHeight * scale
+		title := line + line/2 + 8
+		legend := line + 8
+		top := title + legend + line + 8
+		row := line + 6
+		header := 0
+		if config.GroupBy != "" {
+			header = line + 12
+		}
+		fitted := top + len(groups)*header + len(prs)*row + 10
+		if height == 0 {
+			height = fitted
+			if height > pngMaxHeight {
+				height = pngMaxHeight
+			}
+		}
+		if height != fitted && len(prs) > 0 {
+			row = (height - top - len(groups)*header - 10) / len(prs)
+			if row < 2 {
+				row = 2
+			}
+		}
+		x := func(t time.Time) int {
+			p := axis.Position(t)
+			if p < 0 {
+				p = 0
+			} else if p > 1 {
+				p = 1
+			}
+			return int(p*float64(width) + 0.5)
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		text, grid := pngColor(theme.TextColor), pngColor(theme.GridColor)
+		fill(img, img.Bounds(), pngColor(theme.BackgroundColor))
+		drawText(img, 0, 4, chartTitle(config), text, scale+scale/2)
+
+		// legend
+		lx := 0
+		tiers := append(theme.tiers(), Tier{Name: "closed", Color: theme.ClosedColor})
+		for i, t := range tiers {
+			name := t.Name
+			if t.Name != "closed" {
+				name += " " + tierRange(tiers[:len(tiers)-1], i)
+			}
+			fill(img, image.Rect(lx, title, lx+line-2, title+line-2), pngColor(t.Color))
+			drawText(img, lx+line+2, title, name, grid, scale)
+			lx += line + 2 + textWidth(name, scale) + 3*glyphWidth*scale
+		}
+
+		// axis, with days off shaded behind the grid lines
+		for _, p := range axis.DaysOff {
+			draw.Draw(img, image.Rect(x(p.from), title+legend, x(p.to), height), &image.Uniform{color.NRGBA{128, 128, 128, 38}}, image.ZP, draw.Over)
+		}
+		// labels are drawn smaller, then cut short, to fit between the lines
+		labels := axis.Labels()
+		small := scale
+		for _, label := range labels {
+			if textWidth(label, scale) > width/10-4 {
+				small = 1
+			}
+		}
+		for i, label := range labels {
+			fill(img, image.Rect(i*width/10, title+legend, i*width/10+1, height), grid)
+			for textWidth(label, small) > width/10-4 {
+				label = label[:len(label)-1]
+			}
+			drawText(img, i*width/10+width/20-textWidth(label, small)/2, title+legend+2+(scale-small)*glyphHeight/2, label, grid, small)
+		}
+
+		y := top
+		for _, g := range groups {
+			if config.GroupBy != "" {
+				open, age := groupSummary(g, config)
+				drawText(img, 0, y+8, g.Name, text, scale)
+				drawText(img, textWidth(g.Name+" ", scale), y+8, fmt.Sprintf("%d open, median age %s", open, age/time.Minute*time.Minute), grid, scale)
+				y += header
+			}
+			for _, pr := range g.PRs {
+				bar := newChartBar(pr, config, g.Name)
+				for _, s := range bar.Segments {
+					if s.to.Before(axis.Start) || s.from.After(axis.End) {
+						continue
+					}
+					fill(img, image.Rect(x(s.from), y+row/10+1, x(s.to), y+row-row/10-1), pngColor(bar.Color))
+				}
+				label := fmt.Sprintf("%s/%s #%d %s by %s", pr.Owner, pr.Repo, pr.Number, pr.Title, pr.Author)
+				if pr.Grouped > 0 {
+					label = fmt.Sprintf("%s/%s %s", pr.Owner, pr.Repo, pr.Title)
+				}
+				if row >= line+2 {
+					drawText(img, 4, y+(row-line)/2+1, label, text, scale)
+				} else if row >= glyphHeight+2 {
+					drawText(img, 4, y+(row-glyphHeight)/2+1, label, text, 1)
+				}
+				y += row
+			}
+		}
+
+		if err := png.Encode(w, img); err != nil {
+			diag.Report("writing png: %s", err)
+		}
+		out <- true
+		close(out)
+	}()
+	return out
+}
+
+// fill paints r on img in c.
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.ZP, draw.Src)
+}
+
+// pngColor converts a CSS hex color for drawing. Other CSS colors can't
+// be read, so are drawn grey.
+func pngColor(s string) color.Color {
+	rgb, ok := parseHexColor(s)
+	if !ok {
+		return color.RGBA{153, 153, 153, 255}
+	}
+	return color.RGBA{uint8(rgb[0]), uint8(rgb[1]), uint8(rgb[2]), 255}
+}
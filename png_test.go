@@ -0,0 +1,144 @@
+package prmonitor
+
+import (
+	"bytes"
+	"github.com/google/go-github/github"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPNG(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 2)
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "old pr", Author: "LK4D4",
+		OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"}
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 2, Title: "ancient pr", Author: "LK4D4",
+		OpenedAt: now.Add(-1000 * time.Hour), ClosedAt: now.Add(-300 * time.Hour), State: "closed"}
+	close(in)
+
+	var w bytes.Buffer
+	<-PNG(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil, 1000, 0)
+	img, err := png.Decode(&w)
+	if err != nil {
+		t.Logf("ERROR: invalid png: %s", err)
+		t.FailNow()
+	}
+	if img.Bounds() != image.Rect(0, 0, 1000, 134) {
+		t.Logf("ERROR: expected a 1000x134 image, but got %s", img.Bounds())
+		t.Fail()
+	}
+	for _, c := range []struct {
+		x, y     int
+		expected color.RGBA
+	}{
+		{990, 10, color.RGBA{0x33, 0x33, 0x33, 0xff}},  // background
+		{850, 84, color.RGBA{0xcc, 0x00, 0x00, 0xff}},  // the open PR, from 72 hours ago to now
+		{620, 84, color.RGBA{0x33, 0x33, 0x33, 0xff}},  // before it opened
+		{850, 106, color.RGBA{0x33, 0x33, 0x33, 0xff}}, // the closed PR is off the axis
+	} {
+		if color.RGBAModel.Convert(img.At(c.x, c.y)) != c.expected {
+			t.Logf("ERROR: expected %v at %d,%d, but got %v", c.expected, c.x, c.y, img.At(c.x, c.y))
+			t.Fail()
+		}
+	}
+}
+
+func TestPNGHeight(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 1)
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "old pr", Author: "LK4D4",
+		OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"}
+	close(in)
+
+	var w bytes.Buffer
+	<-PNG(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil, 400, 300)
+	img, err := png.Decode(&w)
+	if err != nil {
+		t.Logf("ERROR: invalid png: %s", err)
+		t.FailNow()
+	}
+	if img.Bounds() != image.Rect(0, 0, 400, 300) {
+		t.Logf("ERROR: expected a 400x300 image, but got %s", img.Bounds())
+		t.Fail()
+	}
+	// the only row is stretched to fill the image
+	if r, _, _, _ := img.At(300, 250).RGBA(); r>>8 != 0xcc {
+		t.Logf("ERROR: expected the bar to reach the bottom of the image, but got %v", img.At(300, 250))
+		t.Fail()
+	}
+}
+
+func TestPNGMaxHeight(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 1000)
+	for i := 0; i < 1000; i++ {
+		in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: i, Title: "pr", Author: "LK4D4",
+			OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"}
+	}
+	close(in)
+
+	var w bytes.Buffer
+	<-PNG(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil, 400, 0)
+	img, err := png.Decode(&w)
+	if err != nil {
+		t.Logf("ERROR: invalid png: %s", err)
+		t.FailNow()
+	}
+	if img.Bounds() != image.Rect(0, 0, 400, pngMaxHeight) {
+		t.Logf("ERROR: expected the image to be cut to 400x%d, but got %s", pngMaxHeight, img.Bounds())
+		t.Fail()
+	}
+	// rows are squeezed so every bar still fits
+	bars, onBar := 0, false
+	for y := 0; y < pngMaxHeight; y++ {
+		r, _, _, _ := img.At(310, y).RGBA()
+		if r>>8 == 0xcc && !onBar {
+			bars++
+		}
+		onBar = r>>8 == 0xcc
+	}
+	if bars != 1000 {
+		t.Logf("ERROR: expected 1000 bars, but got %d", bars)
+		t.Fail()
+	}
+}
+
+func TestChartPNG(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	handler := ChartPNG(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}}, client)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/chart.png?width=640&height=480", nil)
+	req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+	handler(w, req)
+	if w.Header().Get("Content-Type") != "image/png" {
+		t.Logf("ERROR: expected a png image, but got %s", w.Header().Get("Content-Type"))
+		t.Fail()
+	}
+	if img, err := png.Decode(w.Body); err != nil || img.Bounds() != image.Rect(0, 0, 640, 480) {
+		t.Logf("ERROR: expected a 640x480 png, but got %v", err)
+		t.Fail()
+	}
+
+	for _, q := range []string{"width=abc", "width=10", "height=100000"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/chart.png?"+q, nil)
+		req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+		handler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Logf("ERROR: expected %s to be rejected, but got %d", q, w.Code)
+			t.Fail()
+		}
+	}
+}
@@ -1,19 +1,25 @@
 package prmonitor
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/google/go-github/github"
+	"html"
 	"io"
 	"net/http"
-	"sort"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
-	"context"
 )
 
 // Data Structures
 
+// GhostUser is shown as the author of pull requests whose account has
+// been deleted, matching the placeholder github uses.
+const GhostUser = "ghost"
+
 // SummarizedPullRequest contains information necessary to
 // render a PR.
 type SummarizedPullRequest struct {
@@ -29,6 +35,9 @@ type SummarizedPullRequest struct {
 	// the title of the PR
 	Title string
 
+	// the PR's page on github
+	URL string
+
 	// the username of the author of the PR.
 	Author string
 
@@ -38,6 +47,57 @@ type SummarizedPullRequest struct {
 	// the time the PR was closed (or the current time).
 	ClosedAt time.Time
 
+	// whether github reported a close time for the PR. It keeps the
+	// last close time of PRs that were reopened, so this only says
+	// whether TrackReopens needs to look for gaps; open PRs still
+	// run until now.
+	WasClosed bool
+
+	// the time the PR was last updated.
+	UpdatedAt time.Time
+
+	// periods the PR spent closed before being reopened.
+	Gaps []Gap
+
+	// labels attached to the PR.
+	Labels []Label
+
+	// whether the PR was opened by a bot.
+	Bot bool
+
+	// for summary rows standing in for several bot PRs, the number of
+	// PRs grouped together.
+	Grouped int
+
+	// the sha of the PR's head commit.
+	HeadSHA string
+
+	// the CODEOWNERS users and teams owning the files the PR changes.
+	CodeOwners []string
+
+	// the users and teams whose review has been requested but not yet
+	// given. Requested teams are only known with the GraphQL API.
+	RequestedReviewers []string
+	RequestedTeams     []string
+
+	// whether the PR is waiting on reviewers ("review_required"), its
+	// author ("changes_requested"), or neither ("approved"). Empty if
+	// unknown; the REST API only tells whether reviews are requested.
+	ReviewState string
+
+	// the build status of the PR's head commit: "success", "failure",
+	// "pending", or empty if unknown.
+	CIStatus string
+
+	// when the build started failing, if it is.
+	CIFailedAt time.Time
+
+	// how big the PR is. Commits is zero if the size isn't known.
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	Commits      int
+
 	// Current state of the pr: Either open, closed, or all to filter by state. Default: open
 	State string
 }
@@ -60,15 +120,84 @@ type Config struct {
 	// Repos to pull onto dashboard
 	Repos []Repo
 
+	// Which github API to retrieve pull requests with: "rest" (the
+	// default) or "graphql", which fetches many repos per request.
+	API string
+
+	// number of repos fetched in parallel for each of the open and
+	// closed listings. Defaults to 4.
+	Concurrency int
+
+	// default number of seconds to wait for a repo before giving up
+	// on it, unless the repo sets its own. Zero means no timeout.
+	Timeout int
+
+	// look up when each PR was closed and reopened, so reopened PRs
+	// are drawn with gaps. Costs a request per PR with the REST API.
+	TrackReopens bool
+
+	// look up how many lines each PR changes, to show size badges and
+	// how long PRs of each size take to review. Costs a request per
+	// PR with the REST API.
+	TrackSize bool
+
 	// optional list of authors - if included, will only display open PRs
 	// by those authors. Useful for filtering large codebases by team.
 	Authors *[]string
 
+	// optional lists of labels - if IncludeLabels is given, only PRs
+	// with one of those labels are displayed, and PRs with any of the
+	// ExcludeLabels are hidden. Useful where labels assign ownership.
+	IncludeLabels []string
+	ExcludeLabels []string
+
+	// How to display PRs opened by bots such as dependabot
+	Bots Bots
+
+	// optional list of CODEOWNERS teams or users - if included, will
+	// only display PRs changing files those owners must review. Useful
+	// for seeing the PRs waiting on a team, not just written by it.
+	CodeOwners []string
+
+	// show the code owners of each PR even when not filtering by them.
+	ShowCodeOwners bool
+
+	// How to show and color PRs by their build status
+	CI CI
+
+	// optional working hours and holidays. If given, PRs only age
+	// during working hours, so PassiveTime and WarningTime are working
+	// hours too, and days off are shaded on the dashboard.
+	Calendar *Calendar
+
+	// IANA time zone days and times are shown in, e.g.
+	// "America/Los_Angeles". Defaults to the Calendar's time zone, or
+	// UTC. Can be overridden with ?tz= on each request.
+	TimeZone string
+
 	// How to sort the dashboard
 	Sort SortBy
 
+	// optional swimlanes to split the dashboard into: "repo",
+	// "author", "team" or "label". PRs are sorted within each lane.
+	GroupBy string
+
+	// team names and their members' logins, for grouping by team.
+	Teams map[string][]string
+
+	// optional color customizations for swimlanes by name, falling
+	// back to Customization for anything they don't set.
+	Groups map[string]*Customization
+
+	// optional heading for the dashboard, "Recent Pull Requests" by default
+	Title string
+
 	// Color customizations for display
 	Customization Customization
+
+	// the query parameters of the request being served, shown in the
+	// filter bar. Only dashboards served over http have one.
+	query url.Values
 }
 
 // Repo is a single repository that should be monitored and the
@@ -81,6 +210,21 @@ type Repo struct {
 
 	// number of open PRs to look through - can be tuned for each repo.
 	Depth int
+
+	// seconds to wait for this repo before giving up on it. Falls
+	// back to Config.Timeout.
+	Timeout int
+
+	// optional directories or glob patterns - if included, only PRs
+	// changing files under IncludePaths, other than those under
+	// ExcludePaths, are displayed. Useful for teams owning part of a
+	// monorepo.
+	IncludePaths []string
+	ExcludePaths []string
+
+	// optional color customizations for this repo's PRs, falling back
+	// to those of its swimlane and then Config.Customization.
+	Customization *Customization
 }
 
 // GetCustomizations is the easy way to get default customizations
@@ -89,7 +233,7 @@ func GetCustomizations() Customization {
 		PassiveColor: "#00cc66",
 		WarningColor: "#ffff00",
 		AlertColor:   "#cc0000",
-		ClosedColor: "#999",
+		ClosedColor:  "#999",
 		PassiveTime:  24.0,
 		WarningTime:  48,
 	}
@@ -103,10 +247,38 @@ type Customization struct {
 	ClosedColor  string  // #999
 	PassiveTime  float64 // 24.0
 	WarningTime  float64 // 48
+
+	// optional list of tiers to use instead of the passive, warning
+	// and alert colors and times above, for more than three colors.
+	Tiers []Tier
+
+	// blend smoothly between the tier colors by age, rather than
+	// changing color at each threshold. Needs hex colors.
+	Gradient bool
+
+	// optional multipliers for PassiveTime and WarningTime by size
+	// badge, e.g. {"XL": 3}, since big PRs take longer to review.
+	SizeScale map[string]float64
+
+	// built-in page colors, "dark" (the default) or "light", and tier
+	// colors, "default", "colorblind" or "tol". Any colors set above
+	// or below take precedence.
+	Theme   string
+	Palette string
+
+	BackgroundColor string // #333
+	TextColor       string // #fff
+	GridColor       string // #999, also used for less important text
+	Font            string // optional CSS font-family
+
+	// draw a shape for each tier on the bars, so they can be told
+	// apart without relying on color.
+	Icons bool
 }
 
 // SortBy describes how the user wants to sort SummarizedPullRequests on the
-// dashboard. Supported values are "date", "repo".
+// dashboard. Supported values are "date", "repo", "age", "author", "size",
+// "updated" and "review", which can be combined as in "repo,age".
 type SortBy string
 
 // Middlewares
@@ -154,60 +326,310 @@ func Timestamp(next http.HandlerFunc) http.HandlerFunc {
 
 // Dashboard responds to an http request with a dashboard displaying
 // the configured pull requests by pulling information down from
-// github.
+// github. Repos are fetched by a pool of Config.Concurrency workers,
+// and outstanding requests are cancelled if the client disconnects.
 func Dashboard(t Config, client *github.Client) http.HandlerFunc {
+	d := newDashboard(client)
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.serve(w, r, t, Display)
+	}
+}
+
+// ReviewQueue responds to an http request with a personal dashboard
+// of the open pull requests waiting on a review from the user named by
+// the login query parameter, or the authenticated github user if there
+// isn't one, or from a team in t.Teams they are a member of. PRs are
+// sorted by how long they have been open, not by when the review was
+// requested, which would cost another request per PR to find out.
+func ReviewQueue(t Config, client *github.Client) http.HandlerFunc {
+	d := newDashboard(client)
+	var mu sync.Mutex
+	var self string
 	return func(w http.ResponseWriter, r *http.Request) {
-		now, err := time.Parse(time.RFC3339, r.Header.Get("X-Timestamp"))
-		if err != nil {
-			panic(err)
+		login := r.URL.Query().Get("login")
+		if login == "" {
+			mu.Lock()
+			if self == "" {
+				user, _, err := client.Users.Get(r.Context(), "")
+				if err == nil {
+					self = user.GetLogin()
+				}
+			}
+			login = self
+			mu.Unlock()
+		}
+		if login == "" {
+			http.Error(w, "couldn't work out whose reviews to show, try ?login=", http.StatusBadRequest)
+			return
+		}
+
+		c := t
+		c.Sort = "age"
+		c.Title = fmt.Sprintf("Reviews requested from %s", login)
+		d.serve(w, r, c, Display, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByReviewer(in, login, t.Teams)
+		})
+	}
+}
+
+// dashboard holds what a dashboard handler keeps between requests.
+type dashboard struct {
+	client *github.Client
+
+	// shared across requests so the quota is tracked between page loads
+	// and changed files are only fetched once per commit
+	limiter *RateLimiter
+	files   *ChangedFiles
+}
+
+func newDashboard(client *github.Client) *dashboard {
+	limiter := NewRateLimiter()
+	return &dashboard{
+		client:  client,
+		limiter: limiter,
+		files:   NewChangedFiles(client, limiter),
+	}
+}
+
+// renderer draws the pull requests coming out of the pipeline, like
+// Display does.
+type renderer func(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics) <-chan bool
+
+// serve builds and runs the pipeline for a single request, drawing
+// the result with render. Any extra stages are run after the
+// configured filters that don't need API calls, and before the ones
+// that do, so they must be cheap.
+func (d *dashboard) serve(w http.ResponseWriter, r *http.Request, t Config, render renderer, extra ...func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest) {
+	now, err := time.Parse(time.RFC3339, r.Header.Get("X-Timestamp"))
+	if err != nil {
+		panic(err)
+	}
+	t, stages, err := withQuery(t, r.URL.Query(), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.run(r.Context(), w, now, t, render, append(stages, extra...)...)
+}
+
+// withQuery applies the query parameters of a dashboard request to t:
+// the time zone, the sort order and the filters, which are returned as
+// extra pipeline stages. Repos left out by a repo filter aren't
+// retrieved at all.
+func withQuery(t Config, q url.Values, now time.Time) (Config, []func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest, error) {
+	if tz := q.Get("tz"); tz != "" {
+		t.TimeZone = tz
+	}
+	if _, err := t.location(); err != nil {
+		return t, nil, fmt.Errorf("unknown time zone %q", t.TimeZone)
+	}
+	if s := q.Get("sort"); s != "" {
+		t.Sort = SortBy(s)
+		if _, err := t.Sort.Keys(); err != nil {
+			return t, nil, err
+		}
+	}
+	stages, err := queryFilters(q, now)
+	if err != nil {
+		return t, nil, err
+	}
+	if repos := queryValues(q, "repo"); len(repos) > 0 {
+		var matching []Repo
+		for _, r := range t.Repos {
+			if repoMatches(repos, r.Owner, r.Repo) {
+				matching = append(matching, r)
+			}
+		}
+		t.Repos = matching
+	}
+	t.query = q
+	return t, stages, nil
+}
+
+// run retrieves the pull requests of the configured repos at now and
+// draws them with render, returning once it is done. Extra stages are
+// run as serve describes.
+func (d *dashboard) run(ctx context.Context, w io.Writer, now time.Time, t Config, render renderer, extra ...func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest) {
+	client, limiter, files := d.client, d.limiter, d.files
+	diag := &Diagnostics{}
+	if t.Calendar != nil {
+		if err := t.Calendar.Load(); err != nil {
+			diag.Report("loading calendar: %s", err)
 		}
+	}
+	workers := t.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
 
+	// choose the retrieval stage for the configured API, fanning
+	// out to several workers that all read from the same source.
+	var sources []chan Repo
+	var retrievers []<-chan SummarizedPullRequest
+	switch t.API {
+	case "graphql":
+		repos := make(chan Repo)
+		sources = []chan Repo{repos}
+		for i := 0; i < workers; i++ {
+			retrievers = append(retrievers, RetrieveGraphQL(ctx, repos, client, limiter, diag, now))
+		}
+	default:
 		opened := make(chan Repo)
 		closed := make(chan Repo)
-
-		// construct pipeline
-		done := Display(
-			FilterByAuthor(
-				FilterByDate(
-					merge(
-						Retrieve(opened, client, now, "open", "created"),
-						Retrieve(closed, client, now, "closed", "updated"),
-					), now),
-				t.Authors),
-			w, now, t.Sort, t)
-
-		for _, repo := range t.Repos {
-			opened <- repo
-			closed <- repo
+		sources = []chan Repo{opened, closed}
+		for i := 0; i < workers; i++ {
+			retrievers = append(retrievers,
+				Retrieve(ctx, opened, client, limiter, diag, now, "open", "created"),
+				Retrieve(ctx, closed, client, limiter, diag, now, "closed", "updated"),
+			)
 		}
-		close(opened)
-		close(closed)
+	}
+	retrieved := Deduplicate(merge(retrievers...))
+
+	// construct pipeline, with the filters that need more API
+	// calls after the ones that don't
+	filtered := FilterByDate(retrieved, now, t)
+	filtered = FilterByAuthor(filtered, t.Authors)
+	filtered = FilterByLabel(filtered, t.IncludeLabels, t.ExcludeLabels)
+	for _, stage := range extra {
+		filtered = stage(filtered)
+	}
+	if t.TrackReopens && t.API != "graphql" {
+		filtered = TrackReopens(ctx, filtered, client, limiter, diag)
+	}
+	filtered = FilterByPath(ctx, filtered, t.Repos, files, diag)
+	if len(t.CodeOwners) > 0 || t.ShowCodeOwners {
+		filtered = FilterByCodeOwners(ctx, filtered, client, limiter, files, t.CodeOwners, diag)
+	}
+	if t.TrackSize && t.API != "graphql" {
+		filtered = TrackSize(ctx, filtered, client, limiter, diag)
+	}
+	if t.CI.Enabled && t.API != "graphql" {
+		filtered = fanOut(workers, filtered, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return TrackCIStatus(ctx, in, client, limiter, diag)
+		})
+	}
+	filtered = HandleBots(filtered, t.Bots)
+	done := render(filtered, w, now, t.Sort, t, limiter, diag)
 
-		<-done
+feed:
+	for _, repo := range t.Repos {
+		if repo.Timeout == 0 {
+			repo.Timeout = t.Timeout
+		}
+		for _, s := range sources {
+			select {
+			case s <- repo:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	for _, s := range sources {
+		close(s)
 	}
+
+	<-done
 }
 
 // Data processing pipeline...
 
 // Transform converts a github pull request into a pointer-free summary
-// that can be used by the rest of the pipeline.
+// that can be used by the rest of the pipeline. Pull requests missing
+// the data needed to draw them are rejected with a descriptive error,
+// while those by deleted users are attributed to GhostUser.
 func Transform(v *github.PullRequest, now time.Time) (SummarizedPullRequest, error) {
+	if v == nil {
+		return SummarizedPullRequest{}, fmt.Errorf("empty pull request")
+	}
+	name := "pull request"
+	if v.HTMLURL != nil {
+		name = *v.HTMLURL
+	} else if v.Number != nil {
+		name = fmt.Sprintf("pull request #%d", *v.Number)
+	}
+	switch {
+	case v.Number == nil:
+		return SummarizedPullRequest{}, fmt.Errorf("%s has no number", name)
+	case v.Base == nil || v.Base.Repo == nil || v.Base.Repo.Name == nil:
+		return SummarizedPullRequest{}, fmt.Errorf("%s has no base repository", name)
+	case v.Base.Repo.Owner == nil || v.Base.Repo.Owner.Login == nil:
+		return SummarizedPullRequest{}, fmt.Errorf("%s has no base repository owner", name)
+	case v.CreatedAt == nil:
+		return SummarizedPullRequest{}, fmt.Errorf("%s has no creation time", name)
+	case v.State == nil:
+		return SummarizedPullRequest{}, fmt.Errorf("%s has no state", name)
+	}
+
+	// reopened PRs keep their last close time, so only trust it once
+	// the PR is closed again
 	closedAt := now
-	if v.ClosedAt != nil {
+	wasClosed := v.ClosedAt != nil
+	if wasClosed && *v.State != "open" {
 		closedAt = *v.ClosedAt
 	}
+	updatedAt := *v.CreatedAt
+	if v.UpdatedAt != nil {
+		updatedAt = *v.UpdatedAt
+	}
+	author := GhostUser
+	if v.User != nil && v.User.Login != nil {
+		author = *v.User.Login
+	}
+	var reviewers []string
+	for _, u := range v.RequestedReviewers {
+		if u != nil && u.Login != nil {
+			reviewers = append(reviewers, *u.Login)
+		}
+	}
+	var reviewState string
+	if len(reviewers) > 0 {
+		reviewState = "review_required"
+	}
+	var headSHA string
+	if v.Head != nil && v.Head.SHA != nil {
+		headSHA = *v.Head.SHA
+	}
+	var labels []Label
+	for _, l := range v.Labels {
+		if l != nil && l.Name != nil {
+			labels = append(labels, Label{Name: *l.Name, Color: l.GetColor()})
+		}
+	}
 	return SummarizedPullRequest{
-		Owner:    *v.Base.Repo.Owner.Login,
-		Repo:     *v.Base.Repo.Name,
-		Number:   *v.Number,
-		Title:    *v.Title,
-		Author:   *v.User.Login,
-		OpenedAt: *v.CreatedAt,
-		ClosedAt: closedAt,
-		State: *v.State,
+		Owner:     *v.Base.Repo.Owner.Login,
+		Repo:      *v.Base.Repo.Name,
+		Number:    *v.Number,
+		Title:     v.GetTitle(),
+		URL:       v.GetHTMLURL(),
+		Author:    author,
+		OpenedAt:  *v.CreatedAt,
+		ClosedAt:  closedAt,
+		WasClosed: wasClosed,
+		UpdatedAt: updatedAt,
+		Labels:    labels,
+		HeadSHA:   headSHA,
+		State:     *v.State,
+
+		RequestedReviewers: reviewers,
+		ReviewState:        reviewState,
+		Additions:          v.GetAdditions(),
+		Deletions:          v.GetDeletions(),
+		ChangedFiles:       v.GetChangedFiles(),
+		Commits:            v.GetCommits(),
 	}, nil
 }
 
+// fanOut runs workers copies of stage, all reading from in, so that
+// stages making API calls for each PR make them concurrently.
+func fanOut(workers int, in <-chan SummarizedPullRequest, stage func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+	var outs []<-chan SummarizedPullRequest
+	for i := 0; i < workers; i++ {
+		outs = append(outs, stage(in))
+	}
+	return merge(outs...)
+}
+
 func merge(cs ...<-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
 	var wg sync.WaitGroup
 	out := make(chan SummarizedPullRequest)
@@ -229,8 +651,12 @@ func merge(cs ...<-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
 }
 
 // Retrieve pulls in a repository and fetches pull requests that
-// are passed to the next stage in the pipeline.
-func Retrieve(in chan Repo, client *github.Client, now time.Time, state string, sort string) <-chan SummarizedPullRequest {
+// are passed to the next stage in the pipeline. Requests are paced
+// and retried by limiter; repos that still fail, time out or are
+// cancelled through ctx are skipped. Several Retrieve stages can read
+// from the same channel to fetch repos in parallel. Skipped repos and
+// pull requests are reported to diag.
+func Retrieve(ctx context.Context, in chan Repo, client *github.Client, limiter *RateLimiter, diag *Diagnostics, now time.Time, state string, sort string) <-chan SummarizedPullRequest {
 	out := make(chan SummarizedPullRequest)
 	go func() {
 		for r := range in {
@@ -241,14 +667,26 @@ func Retrieve(in chan Repo, client *github.Client, now time.Time, state string,
 			op.Page = 0
 			op.Base = "master"
 			op.PerPage = r.Depth
-			oprs, _, err := client.PullRequests.List(client.Context().Background(), r.Owner, r.Repo, op)
+			rctx, cancel := repoContext(ctx, r)
+			var oprs []*github.PullRequest
+			err := limiter.Do(rctx, func() (*github.Response, error) {
+				var resp *github.Response
+				var err error
+				oprs, resp, err = client.PullRequests.List(rctx, r.Owner, r.Repo, op)
+				return resp, err
+			})
+			cancel()
 			if err != nil {
-				return
+				diag.Report("%s/%s: couldn't list %s pull requests: %s", r.Owner, r.Repo, state, err)
+				continue
 			}
 			for _, v := range oprs {
-				if p, err := Transform(v, now); err == nil {
-					out <- p
+				p, err := Transform(v, now)
+				if err != nil {
+					diag.Report("%s/%s: dropped %s", r.Owner, r.Repo, err)
+					continue
 				}
+				out <- p
 			}
 		}
 		close(out)
@@ -256,33 +694,72 @@ func Retrieve(in chan Repo, client *github.Client, now time.Time, state string,
 	return out
 }
 
-// FilterByDate drops Summarized Pull Requests that were closed more
-// than 10 days ago.
-func FilterByDate(in <-chan SummarizedPullRequest, now time.Time) <-chan SummarizedPullRequest {
+// FilterByReviewer drops SummarizedPullRequests that aren't open and
+// waiting on a review from login, either directly or from one of the
+// teams login is a member of in teams.
+func FilterByReviewer(in <-chan SummarizedPullRequest, login string, teams map[string][]string) <-chan SummarizedPullRequest {
 	out := make(chan SummarizedPullRequest)
 	go func() {
 		for v := range in {
-			if now.Sub(v.ClosedAt) < 240*time.Hour {
+			if v.State == "open" && reviewRequested(v, login, teams) {
 				out <- v
 			}
 		}
 		close(out)
-
 	}()
 	return out
 }
 
+// reviewRequested reports whether pr is waiting on a review from login
+// or one of their teams.
+func reviewRequested(pr SummarizedPullRequest, login string, teams map[string][]string) bool {
+	for _, r := range pr.RequestedReviewers {
+		if strings.EqualFold(r, login) {
+			return true
+		}
+	}
+	for _, r := range pr.RequestedTeams {
+		for team, members := range teams {
+			if !strings.EqualFold(team, r) {
+				continue
+			}
+			for _, m := range members {
+				if strings.EqualFold(m, login) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// repoContext applies the repo's timeout, if it has one, to ctx.
+func repoContext(ctx context.Context, r Repo) (context.Context, context.CancelFunc) {
+	if r.Timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(r.Timeout)*time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+// FilterByDate drops Summarized Pull Requests that were closed before
+// the chart drawn at now starts, at midnight 10 days ago in the
+// display time zone, so every PR shown has a bar.
+func FilterByDate(in <-chan SummarizedPullRequest, now time.Time, config Config) <-chan SummarizedPullRequest {
+	return FilterByWindow(in, now, now.Sub(newChartAxis(now, config).Start))
+}
+
 // FilterByAuthor drops SummarizedPullRequests that don't belong to
-// a team member (provided the array exists)
+// a team member (provided the array exists). Logins are compared
+// ignoring case, as github does.
 func FilterByAuthor(in <-chan SummarizedPullRequest, authors *[]string) <-chan SummarizedPullRequest {
 	out := make(chan SummarizedPullRequest)
 	go func() {
 		for v := range in {
 			if authors != nil {
 				for _, a := range *authors {
-					if a == v.Author {
+					if strings.EqualFold(a, v.Author) {
 						out <- v
-						continue
+						break
 					}
 				}
 			} else {
@@ -296,42 +773,117 @@ func FilterByAuthor(in <-chan SummarizedPullRequest, authors *[]string) <-chan S
 }
 
 // Display formats pull requests onto a html page as they
-// come in from the rest of the pipeline.
-func Display(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config) <-chan bool {
+// come in from the rest of the pipeline. If limiter is given, the
+// github API quota is shown in the footer, along with any problems
+// reported to diag while the pull requests were gathered.
+func Display(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics) <-chan bool {
 	out := make(chan bool)
 	go func() {
-		fmt.Fprintf(w, "<html><head><meta http-equiv='refresh' content='86400'></head><body style='background: #333; color: #fff; width: 50%; margin: 0 auto;'>")
-		fmt.Fprintf(w, "<h1>Recent Pull Requests</h1>")
-		fmt.Fprintf(w, "<div style='background-image: linear-gradient(90deg, #999 0%%, #999 1%%, transparent 1%%); background-size: 10%% 100%%; background-repeat: repeat-x;'>")
-		for i := 10; i > 0; i-- {
-			if i == 1 {
-				fmt.Fprintf(w, "<div style='color: #999; width: 10%%; display: inline-block; text-align: center;'>today</div>")
-			} else {
-				fmt.Fprintf(w, "<div style='color: #999; width: 10%%; display: inline-block; text-align: center;'>%d days ago</div>", i)
+		config.Customization = config.Customization.WithDefaults()
+		theme := config.Customization
+		font := ""
+		if theme.Font != "" {
+			font = fmt.Sprintf(" font-family: %s;", theme.Font)
+		}
+		fmt.Fprintf(w, "<html><head><meta http-equiv='refresh' content='86400'></head><body style='background: %s; color: %s;%s width: 50%%; margin: 0 auto;'>", theme.BackgroundColor, theme.TextColor, font)
+		fmt.Fprintf(w, "<h1>%s</h1>", html.EscapeString(chartTitle(config)))
+		if config.query != nil {
+			displayFilterBar(w, config.query, theme)
+		}
+		displayLegend(w, theme)
+
+		axis := newChartAxis(now, config)
+		position := func(t time.Time) float64 {
+			p := axis.Position(t) * 100
+			if p < 0 {
+				p = 0
+			} else if p > 100 {
+				p = 100
 			}
+			return p
+		}
+
+		// shade days off behind the grid lines
+		shading := "linear-gradient(transparent, transparent)"
+		if len(axis.DaysOff) > 0 {
+			stops := []string{"transparent 0%"}
+			for _, p := range axis.DaysOff {
+				start, end := position(p.from), position(p.to)
+				stops = append(stops, fmt.Sprintf("transparent %.6f%%, rgba(128, 128, 128, 0.15) %.6f%%, rgba(128, 128, 128, 0.15) %.6f%%, transparent %.6f%%", start, start, end, end))
+			}
+			shading = fmt.Sprintf("linear-gradient(90deg, %s)", strings.Join(stops, ", "))
+		}
+		fmt.Fprintf(w, "<div style='background-image: linear-gradient(90deg, %s 0%%, %s 1%%, transparent 1%%), %s; background-size: 10%% 100%%, 100%% 100%%; background-repeat: repeat-x, no-repeat;'>", theme.GridColor, theme.GridColor, shading)
+		for _, label := range axis.Labels() {
+			fmt.Fprintf(w, "<div style='color: %s; width: 10%%; display: inline-block; text-align: center;'>%s</div>", theme.GridColor, label)
 		}
-		total := (240 * time.Hour).Hours()
 		var prs SummarizedPullRequests
 		for pr := range in {
 			prs = append(prs, pr)
 		}
 
-		switch sortBy {
-		case "repo":
-			sort.Sort(ByRepo{prs})
-		case "date":
-		default:
-			sort.Sort(ByDate{prs})
-		}
+		sortPRs(prs, sortBy, diag)
 
-		for _, pr := range prs {
-			start := (total - now.Sub(pr.OpenedAt).Hours()) / total
-			end := (total - now.Sub(pr.ClosedAt).Hours()) / total
-			color := getColor(config, now.Sub(pr.OpenedAt).Hours()-now.Sub(pr.ClosedAt).Hours(), pr.State)
-			style := fmt.Sprintf(`margin: 2px; background: linear-gradient( 90deg, transparent 0%%, transparent %.6f%%, %s %.6f%%, %s %.6f%%, transparent %.6f%%);`, start*100, color, start*100, color, end*100, end*100)
-			fmt.Fprintf(w, "<div style='%s'><b>%s/%s</b> #%d %s by %s</div>", style, pr.Owner, pr.Repo, pr.Number, pr.Title, pr.Author)
+		for _, g := range groupPRs(prs, config) {
+			if config.GroupBy != "" {
+				open, age := groupSummary(g, config)
+				fmt.Fprintf(w, "<details open><summary style='margin-top: 0.5em; cursor: pointer;'><b>%s</b> <span style='color: %s; font-size: small;'>%d open, median age %s</span></summary>", html.EscapeString(g.Name), theme.GridColor, open, age/time.Minute*time.Minute)
+			}
+			for _, pr := range g.PRs {
+				bar := newChartBar(pr, config, g.Name)
+
+				// one colored segment per period the PR was open
+				stops := []string{"transparent 0%"}
+				for _, s := range bar.Segments {
+					if s.to.Before(axis.Start) || s.from.After(axis.End) {
+						continue
+					}
+					start, end := position(s.from), position(s.to)
+					stops = append(stops, fmt.Sprintf("transparent %.6f%%, %s %.6f%%, %s %.6f%%, transparent %.6f%%", start, bar.Color, start, bar.Color, end, end))
+				}
+				style := fmt.Sprintf(`margin: 2px; background: linear-gradient( 90deg, %s);`, strings.Join(stops, ", "))
+				if pr.Grouped > 0 {
+					fmt.Fprintf(w, "<div style='%s'><b>%s/%s</b> %s</div>", style, html.EscapeString(pr.Owner), html.EscapeString(pr.Repo), html.EscapeString(pr.Title))
+					continue
+				}
+				owners := ""
+				if len(pr.CodeOwners) > 0 {
+					owners = fmt.Sprintf("<span style='color: %s; font-size: small; margin-left: 0.4em;'>owned by %s</span>", theme.GridColor, html.EscapeString(strings.Join(pr.CodeOwners, ", ")))
+				}
+				// rows are only links when the PR has a URL, as in
+				// the SVG chart
+				title := html.EscapeString(tooltip(pr, axis.Location, bar.Age, bar.Tier))
+				if pr.URL != "" {
+					fmt.Fprintf(w, "<a href='%s' title='%s' style='color: inherit; text-decoration: none; display: block;'><div style='%s'>", html.EscapeString(pr.URL), title, style)
+				} else {
+					fmt.Fprintf(w, "<div title='%s' style='%s'>", title, style)
+				}
+				fmt.Fprintf(w, "%s%s%s<b>%s/%s</b> #%d %s by %s%s%s</div>",
+					tierIcon(bar.Customization, bar.Tier), ciBadge(pr, config), sizeBadge(pr),
+					html.EscapeString(pr.Owner), html.EscapeString(pr.Repo), pr.Number, html.EscapeString(pr.Title), html.EscapeString(pr.Author), labelChips(pr), owners)
+				if pr.URL != "" {
+					fmt.Fprintf(w, "</a>")
+				}
+			}
+			if config.GroupBy != "" {
+				fmt.Fprintf(w, "</details>")
+			}
 		}
 		fmt.Fprintf(w, "</div>")
+		displaySizeBreakdown(w, prs, config)
+		if limiter != nil {
+			if rate := limiter.Rate(); rate.Limit > 0 {
+				fmt.Fprintf(w, "<div style='color: %s; font-size: small; margin-top: 1em;'>github API quota: %d of %d remaining, resets at %s (in %d minutes)</div>",
+					theme.GridColor, rate.Remaining, rate.Limit, rate.Reset.In(axis.Location).Format("15:04 MST"), int(rate.Reset.Sub(now).Minutes()))
+			}
+		}
+		if problems := diag.Problems(); len(problems) > 0 {
+			fmt.Fprintf(w, "<div style='color: %s; font-size: small; margin-top: 1em;'>%d problems while loading pull requests:<ul>", theme.GridColor, len(problems))
+			for _, p := range problems {
+				fmt.Fprintf(w, "<li>%s</li>", html.EscapeString(p))
+			}
+			fmt.Fprintf(w, "</ul></div>")
+		}
 		fmt.Fprintf(w, "</body></html>")
 		out <- true
 		close(out)
@@ -339,17 +891,64 @@ func Display(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy
 	return out
 }
 
+// customizationFor resolves the colors and thresholds for a PR in the
+// swimlane named group: bot customizations win over the PR's repo,
+// which wins over its swimlane, which wins over the global ones.
+func customizationFor(pr SummarizedPullRequest, config Config, group string) Customization {
+	c := config.Customization
+	if g := config.Groups[group]; g != nil && config.GroupBy != "" {
+		c = c.override(*g)
+	}
+	if r, ok := findRepo(config.Repos, pr.Owner, pr.Repo); ok && r.Customization != nil {
+		c = c.override(*r.Customization)
+	}
+	if pr.Bot && config.Bots.Customization != nil {
+		c = c.override(*config.Bots.Customization)
+	}
+	return c
+}
+
 func getColor(config Config, openedFor float64, state string) string {
 	customs := config.Customization
 	if state == "closed" {
 		return customs.ClosedColor
 	}
-	if openedFor < customs.PassiveTime {
-		return customs.PassiveColor
-	} else if openedFor < customs.WarningTime {
-		return customs.WarningColor
+	tiers := customs.tiers()
+	if customs.Gradient {
+		return gradientColor(tiers, openedFor)
+	}
+	return tiers[tierFor(tiers, openedFor)].Color
+}
+
+// getTier names the tier a PR is shown in, such as "passive",
+// "warning" or "alert", or "closed".
+func getTier(config Config, openedFor float64, state string) string {
+	if state == "closed" {
+		return "closed"
+	}
+	tiers := config.Customization.tiers()
+	return tiers[tierFor(tiers, openedFor)].Name
+}
+
+// tooltip describes a PR in more detail than fits on its bar, one
+// fact per line.
+func tooltip(pr SummarizedPullRequest, loc *time.Location, age time.Duration, tier string) string {
+	lines := []string{"opened " + pr.OpenedAt.In(loc).Format("Mon Jan 2 15:04 MST")}
+	if pr.State == "closed" {
+		lines = append(lines, "closed "+pr.ClosedAt.In(loc).Format("Mon Jan 2 15:04 MST"))
+	}
+	lines = append(lines, fmt.Sprintf("open for %s (%s)", age/time.Minute*time.Minute, tier))
+	if reviewers := append(append([]string{}, pr.RequestedReviewers...), pr.RequestedTeams...); len(reviewers) > 0 {
+		lines = append(lines, "waiting on "+strings.Join(reviewers, ", "))
+	}
+	if len(pr.Labels) > 0 {
+		var names []string
+		for _, l := range pr.Labels {
+			names = append(names, l.Name)
+		}
+		lines = append(lines, "labels: "+strings.Join(names, ", "))
 	}
-	return customs.AlertColor
+	return strings.Join(lines, "\n")
 }
 
 // Len returns length of the ByDate array
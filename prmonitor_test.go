@@ -1,12 +1,17 @@
 package prmonitor
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/dnaeon/go-vcr/recorder"
 	"github.com/google/go-github/github"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -222,6 +227,7 @@ func TestRender(t *testing.T) {
 			Author:   "brentdrich",
 			OpenedAt: now.Add(-73 * time.Hour),
 			ClosedAt: now,
+			Labels:   []Label{{Name: "bug", Color: "ee0701"}, {Name: "help wanted", Color: "f7c6c7"}},
 			State:    "open",
 		},
 		{
@@ -244,6 +250,19 @@ func TestRender(t *testing.T) {
 			ClosedAt: now.Add(-64 * time.Hour),
 			State:    "closed",
 		},
+		{
+			Owner:    "brentdrich",
+			Repo:     "prmonitor",
+			Number:   9,
+			Title:    "reopened pr",
+			Author:   "brentdrich",
+			OpenedAt: now.Add(-120 * time.Hour),
+			ClosedAt: now,
+			Gaps: []Gap{
+				{ClosedAt: now.Add(-100 * time.Hour), ReopenedAt: now.Add(-30 * time.Hour)},
+			},
+			State: "open",
+		},
 	}
 	f, err := os.Create("tmp.html")
 	if err != nil {
@@ -251,7 +270,7 @@ func TestRender(t *testing.T) {
 	}
 	defer f.Close()
 	c := make(chan SummarizedPullRequest)
-	d := Display(c, f, now, SortBy("date"), Config{Customization: GetCustomizations()})
+	d := Display(c, f, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil)
 	for _, pr := range prs {
 		c <- pr
 	}
@@ -344,3 +363,396 @@ func TestDashboard(t *testing.T) {
 	}
 	fmt.Fprint(f, w.Body.String())
 }
+
+// Retrieve Tests - several stages reading from one channel should fetch
+// repos in parallel, and slow repos should be dropped by their timeout.
+func TestRetrieveParallel(t *testing.T) {
+	var mu sync.Mutex
+	inflight, most := 0, 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		if inflight > most {
+			most = inflight
+		}
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan Repo)
+	var stages []<-chan SummarizedPullRequest
+	for i := 0; i < 4; i++ {
+		stages = append(stages, Retrieve(context.Background(), in, client, NewRateLimiter(), nil, time.Now(), "open", "created"))
+	}
+	out := merge(stages...)
+	for i := 0; i < 8; i++ {
+		in <- Repo{Owner: "brentdrich", Repo: fmt.Sprintf("repo%d", i)}
+	}
+	close(in)
+	for range out {
+	}
+
+	if most != 4 {
+		t.Logf("ERROR: expected %d requests in flight, but got %d", 4, most)
+		t.Fail()
+	}
+}
+
+func TestRetrieveTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "slow") {
+			time.Sleep(2 * time.Second)
+		}
+		w.Write([]byte(`[{"number": 1, "state": "open", "title": "fast pr", "created_at": "2016-10-01T00:00:00Z",
+			"user": {"login": "brentdrich"}, "base": {"repo": {"name": "fast", "owner": {"login": "brentdrich"}}}}]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan Repo)
+	out := Retrieve(context.Background(), in, client, NewRateLimiter(), nil, time.Now(), "open", "created")
+	go func() {
+		in <- Repo{Owner: "brentdrich", Repo: "slow", Timeout: 1}
+		in <- Repo{Owner: "brentdrich", Repo: "fast", Timeout: 1}
+		close(in)
+	}()
+
+	var prs []SummarizedPullRequest
+	for pr := range out {
+		prs = append(prs, pr)
+	}
+	if len(prs) != 1 || prs[0].Repo != "fast" {
+		t.Logf("ERROR: expected only the fast repo's pull request, but got %+v", prs)
+		t.Fail()
+	}
+}
+
+func TestRetrieveCancelled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		w.Write([]byte("[]"))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan Repo)
+	out := Retrieve(ctx, in, client, NewRateLimiter(), nil, time.Now(), "open", "created")
+	start := time.Now()
+	go func() {
+		in <- Repo{Owner: "brentdrich", Repo: "prmonitor"}
+		cancel()
+		close(in)
+	}()
+	for range out {
+	}
+
+	if time.Since(start) > time.Second {
+		t.Logf("ERROR: expected cancellation to abort the request")
+		t.Fail()
+	}
+}
+
+// Transform Tests - malformed pull requests should be rejected with an
+// error instead of panicking, and deleted users shown as ghosts.
+func TestTransformGhostUser(t *testing.T) {
+	now := time.Now()
+	pr := &github.PullRequest{
+		Number:    github.Int(2),
+		Title:     github.String("ghost pr"),
+		State:     github.String("open"),
+		CreatedAt: &now,
+		Base: &github.PullRequestBranch{Repo: &github.Repository{
+			Name:  github.String("prmonitor"),
+			Owner: &github.User{Login: github.String("brentdrich")},
+		}},
+	}
+	p, err := Transform(pr, now)
+	if err != nil {
+		t.Logf("ERROR: unexpected error %s", err)
+		t.Fail()
+		return
+	}
+	if p.Author != GhostUser {
+		t.Logf("ERROR: expected author '%s', but got '%s'", GhostUser, p.Author)
+		t.Fail()
+	}
+}
+
+func TestTransformMalformed(t *testing.T) {
+	now := time.Now()
+	prs := []*github.PullRequest{
+		nil,
+		{Number: github.Int(2), State: github.String("open"), CreatedAt: &now},
+		{Number: github.Int(3), State: github.String("open"), CreatedAt: &now, Base: &github.PullRequestBranch{Repo: &github.Repository{Name: github.String("prmonitor")}}},
+		{HTMLURL: github.String("https://github.com/brentdrich/prmonitor/pull/4")},
+	}
+	for _, pr := range prs {
+		if _, err := Transform(pr, now); err == nil {
+			t.Logf("ERROR: expected an error for %v", pr)
+			t.Fail()
+		}
+	}
+}
+
+func TestRetrieveReportsDropped(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"number": 1, "html_url": "https://github.com/brentdrich/prmonitor/pull/1", "state": "open", "created_at": "2016-10-01T00:00:00Z"}]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	diag := &Diagnostics{}
+	in := make(chan Repo, 1)
+	in <- Repo{Owner: "brentdrich", Repo: "prmonitor"}
+	close(in)
+	for range Retrieve(context.Background(), in, client, NewRateLimiter(), diag, time.Now(), "open", "created") {
+		t.Logf("ERROR: expected malformed pull request to be dropped")
+		t.Fail()
+	}
+
+	problems := diag.Problems()
+	if len(problems) != 1 || !strings.Contains(problems[0], "pull/1 has no base repository") {
+		t.Logf("ERROR: expected dropped pull request to be reported, but got %v", problems)
+		t.Fail()
+	}
+}
+
+// Review Queue Tests - only open PRs waiting on the user's review
+// should be shown, and the user can be given or looked up.
+func TestReviewQueue(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/user":
+			w.Write([]byte(`{"login": "aaronlehmann"}`))
+		case r.URL.Query().Get("state") == "open":
+			w.Write([]byte(`[
+				{"number": 1, "state": "open", "title": "needs my review", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "LK4D4"}, "requested_reviewers": [{"login": "aaronlehmann"}],
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}},
+				{"number": 2, "state": "open", "title": "needs someone else", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "LK4D4"}, "requested_reviewers": [{"login": "stevvooe"}],
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	handler := ReviewQueue(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}, Customization: GetCustomizations()}, client)
+
+	for _, path := range []string{"/me?login=aaronlehmann", "/me"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+		handler(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "Reviews requested from aaronlehmann") {
+			t.Logf("ERROR: %s: expected heading for aaronlehmann", path)
+			t.Fail()
+		}
+		if !strings.Contains(body, "needs my review") || strings.Contains(body, "needs someone else") {
+			t.Logf("ERROR: %s: expected only the PR waiting on aaronlehmann, but got %s", path, body)
+			t.Fail()
+		}
+	}
+}
+
+func TestFilterByReviewerTeams(t *testing.T) {
+	in := make(chan SummarizedPullRequest, 4)
+	in <- SummarizedPullRequest{Number: 1, State: "open", RequestedReviewers: []string{"AaronLehmann"}}
+	in <- SummarizedPullRequest{Number: 2, State: "open", RequestedTeams: []string{"core"}}
+	in <- SummarizedPullRequest{Number: 3, State: "open", RequestedTeams: []string{"docs"}}
+	in <- SummarizedPullRequest{Number: 4, State: "closed", RequestedTeams: []string{"core"}}
+	close(in)
+	teams := map[string][]string{"Core": {"aaronlehmann"}, "docs": {"stevvooe"}}
+	var numbers []int
+	for pr := range FilterByReviewer(in, "aaronlehmann", teams) {
+		numbers = append(numbers, pr.Number)
+	}
+	if fmt.Sprint(numbers) != "[1 2]" {
+		t.Logf("ERROR: expected open PRs waiting on aaronlehmann or their team, but got %v", numbers)
+		t.Fail()
+	}
+}
+
+func TestDisplayTimeZone(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") == "open" {
+			w.Write([]byte(`[
+				{"number": 1, "state": "open", "title": "late night pr", "created_at": "2016-10-02T23:30:00Z",
+				 "user": {"login": "LK4D4"}, "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	handler := Dashboard(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}, Customization: GetCustomizations()}, client)
+
+	tests := []struct {
+		path     string
+		status   int
+		expected []string
+	}{
+		{"/", 200, []string{"opened Sun Oct 2 23:30 UTC", "Sat Oct 1"}},
+		{"/?tz=America/Los_Angeles", 200, []string{"opened Sun Oct 2 16:30 PDT", "Fri Sep 30"}},
+		{"/?tz=Mars/Olympus_Mons", 400, []string{"unknown time zone"}},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.path, nil)
+		req.Header.Set("X-Timestamp", "2016-10-03T01:00:00Z")
+		handler(w, req)
+
+		if w.Code != test.status {
+			t.Logf("ERROR: %s: expected status %d, but got %d", test.path, test.status, w.Code)
+			t.Fail()
+		}
+		for _, e := range test.expected {
+			if !strings.Contains(w.Body.String(), e) {
+				t.Logf("ERROR: %s: expected %q in %s", test.path, e, w.Body.String())
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestDisplayQuotaTimeZone(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest)
+	close(in)
+	limiter := NewRateLimiter()
+	limiter.rate = github.Rate{Limit: 5000, Remaining: 4000, Reset: github.Timestamp{Time: now.Add(30 * time.Minute)}}
+
+	var w bytes.Buffer
+	<-Display(in, &w, now, SortBy("date"), Config{TimeZone: "America/Los_Angeles", Customization: GetCustomizations()}, limiter, nil)
+	if body := w.String(); !strings.Contains(body, "resets at 05:30 PDT (in 30 minutes)") {
+		t.Logf("ERROR: expected the reset time in the display time zone, but got %s", body)
+		t.Fail()
+	}
+}
+
+func TestDisplayLinks(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 2)
+	in <- SummarizedPullRequest{
+		Owner:              "docker",
+		Repo:               "swarmkit",
+		Number:             1,
+		Title:              "escape <script> & 'quotes'",
+		URL:                "https://github.com/docker/swarmkit/pull/1",
+		Author:             "LK4D4",
+		OpenedAt:           now.Add(-30 * time.Hour),
+		ClosedAt:           now,
+		RequestedReviewers: []string{"aaronlehmann"},
+		Labels:             []Label{{Name: "bug", Color: "ee0701"}},
+		State:              "open",
+	}
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 2, Title: "no url", Author: "LK4D4", OpenedAt: now.Add(-time.Hour), ClosedAt: now, State: "open"}
+	close(in)
+
+	var w bytes.Buffer
+	<-Display(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil)
+	body := w.String()
+	if n := strings.Count(body, "<a href="); n != 1 {
+		t.Logf("ERROR: expected only the PR with a URL to be a link, but got %d links in %s", n, body)
+		t.Fail()
+	}
+	for _, e := range []string{
+		"<a href='https://github.com/docker/swarmkit/pull/1'",
+		"<div title='opened Mon Oct 3 11:00 UTC",
+		"opened Sun Oct 2 06:00 UTC\nopen for 30h0m0s (warning)\nwaiting on aaronlehmann\nlabels: bug",
+		"escape &lt;script&gt; &amp; &#39;quotes&#39;",
+	} {
+		if !strings.Contains(body, e) {
+			t.Logf("ERROR: expected %q in %s", e, body)
+			t.Fail()
+		}
+	}
+}
+
+func TestCustomizationFor(t *testing.T) {
+	config := Config{
+		Repos: []Repo{
+			{Owner: "brentdrich", Repo: "infra", Customization: &Customization{PassiveTime: 2, WarningTime: 4}},
+			{Owner: "brentdrich", Repo: "docs"},
+		},
+		GroupBy:       "repo",
+		Groups:        map[string]*Customization{"brentdrich/docs": {PassiveTime: 84, WarningTime: 168, AlertColor: "#ff00ff"}},
+		Bots:          Bots{Customization: &Customization{WarningColor: "#0000ff"}},
+		Customization: GetCustomizations(),
+	}
+	tests := []struct {
+		pr       SummarizedPullRequest
+		hours    float64
+		expected string
+	}{
+		{SummarizedPullRequest{Owner: "brentdrich", Repo: "infra", State: "open"}, 5, config.Customization.AlertColor},
+		{SummarizedPullRequest{Owner: "brentdrich", Repo: "infra", State: "open", Bot: true}, 3, "#0000ff"},
+		{SummarizedPullRequest{Owner: "brentdrich", Repo: "docs", State: "open"}, 100, config.Customization.WarningColor},
+		{SummarizedPullRequest{Owner: "brentdrich", Repo: "docs", State: "open"}, 200, "#ff00ff"},
+		{SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", State: "open"}, 30, config.Customization.WarningColor},
+	}
+	for _, test := range tests {
+		colors := config
+		colors.Customization = customizationFor(test.pr, config, test.pr.Owner+"/"+test.pr.Repo)
+		if got := getColor(colors, test.hours, test.pr.State); got != test.expected {
+			t.Logf("ERROR: %s after %vh: expected %s, but got %s", test.pr.Repo, test.hours, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestFilterByDate(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 3)
+	// the chart starts at midnight on Sep 24, less than 240 hours ago
+	in <- SummarizedPullRequest{Number: 1, ClosedAt: time.Date(2016, 9, 23, 18, 0, 0, 0, time.UTC)}
+	in <- SummarizedPullRequest{Number: 2, ClosedAt: time.Date(2016, 9, 24, 6, 0, 0, 0, time.UTC)}
+	in <- SummarizedPullRequest{Number: 3, ClosedAt: now}
+	close(in)
+	var numbers []int
+	for pr := range FilterByDate(in, now, Config{}) {
+		numbers = append(numbers, pr.Number)
+	}
+	if len(numbers) != 2 || numbers[0] != 2 || numbers[1] != 3 {
+		t.Logf("ERROR: expected only PRs closed after the chart starts, but got %v", numbers)
+		t.Fail()
+	}
+}
+
+func TestDisplaySegmentsOutsideAxis(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 1)
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 1, Author: "LK4D4", State: "open",
+		OpenedAt: now.Add(-400 * time.Hour), ClosedAt: now,
+		Gaps: []Gap{{ClosedAt: now.Add(-300 * time.Hour), ReopenedAt: now.Add(-100 * time.Hour)}}}
+	close(in)
+
+	var w bytes.Buffer
+	<-Display(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil)
+	if strings.Contains(w.String(), " -") {
+		t.Logf("ERROR: expected no gradient stops before the axis, but got %s", w.String())
+		t.Fail()
+	}
+	if !strings.Contains(w.String(), "transparent 53.333333%, #cc0000 53.333333%") {
+		t.Logf("ERROR: expected the reopened segment to be drawn, but got %s", w.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,173 @@
+package prmonitor
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryValues returns the comma separated values of a query parameter,
+// which may also be repeated.
+func queryValues(q url.Values, name string) []string {
+	var values []string
+	for _, v := range q[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// queryFilters builds the extra pipeline stages asked for by the query
+// parameters of a dashboard request: author, repo, label, state and
+// window. They narrow what the configured filters let through.
+func queryFilters(q url.Values, now time.Time) ([]func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest, error) {
+	var stages []func(<-chan SummarizedPullRequest) <-chan SummarizedPullRequest
+	if authors := queryValues(q, "author"); len(authors) > 0 {
+		stages = append(stages, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByAuthor(in, &authors)
+		})
+	}
+	if repos := queryValues(q, "repo"); len(repos) > 0 {
+		stages = append(stages, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByRepo(in, repos)
+		})
+	}
+	if labels := queryValues(q, "label"); len(labels) > 0 {
+		stages = append(stages, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByLabel(in, labels, nil)
+		})
+	}
+	if state := q.Get("state"); state != "" && state != "all" {
+		if state != "open" && state != "closed" {
+			return nil, fmt.Errorf("unknown state %q, expected open, closed or all", state)
+		}
+		stages = append(stages, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByState(in, state)
+		})
+	}
+	if w := q.Get("window"); w != "" {
+		window, err := parseWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		if window > 240*time.Hour {
+			return nil, fmt.Errorf("invalid window %q, the dashboard only shows the last 10 days", w)
+		}
+		stages = append(stages, func(in <-chan SummarizedPullRequest) <-chan SummarizedPullRequest {
+			return FilterByWindow(in, now, window)
+		})
+	}
+	return stages, nil
+}
+
+// parseWindow reads a window as a number of days ("3" or "3d") or a
+// duration ("36h").
+func parseWindow(w string) (time.Duration, error) {
+	if days, err := strconv.Atoi(strings.TrimSuffix(w, "d")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	if d, err := time.ParseDuration(w); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid window %q, expected days like 3d or a duration like 36h", w)
+}
+
+// FilterByRepo drops SummarizedPullRequests that aren't in one of
+// repos, given as "owner/repo" or just "repo".
+func FilterByRepo(in <-chan SummarizedPullRequest, repos []string) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if repoMatches(repos, v.Owner, v.Repo) {
+				out <- v
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// repoMatches reports whether owner/repo is one of repos, given as
+// "owner/repo" or just "repo".
+func repoMatches(repos []string, owner string, repo string) bool {
+	for _, r := range repos {
+		if strings.EqualFold(r, repo) || strings.EqualFold(r, owner+"/"+repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByState drops SummarizedPullRequests that aren't in state.
+func FilterByState(in <-chan SummarizedPullRequest, state string) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if v.State == state {
+				out <- v
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// FilterByWindow drops SummarizedPullRequests that were closed longer
+// than window before now.
+func FilterByWindow(in <-chan SummarizedPullRequest, now time.Time, window time.Duration) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if now.Sub(v.ClosedAt) < window {
+				out <- v
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// displayFilterBar writes a form for narrowing the dashboard with query
+// parameters, filled in from the current ones. Parameters it doesn't
+// show, such as tz, are kept.
+func displayFilterBar(w io.Writer, q url.Values, theme Customization) {
+	input := fmt.Sprintf("style='background: transparent; color: inherit; border: 1px solid %s; margin-right: 0.6em;'", theme.GridColor)
+	fmt.Fprintf(w, "<form method='get' style='color: %s; font-size: small; margin-bottom: 1em;'>", theme.GridColor)
+	for _, name := range []string{"author", "repo", "label"} {
+		fmt.Fprintf(w, "%s <input name='%s' value='%s' size='12' %s>", name, name, html.EscapeString(q.Get(name)), input)
+	}
+	fmt.Fprintf(w, "state <select name='state' %s>", input)
+	for _, state := range []string{"all", "open", "closed"} {
+		selected := ""
+		if q.Get("state") == state {
+			selected = " selected"
+		}
+		fmt.Fprintf(w, "<option%s>%s</option>", selected, state)
+	}
+	fmt.Fprintf(w, "</select>")
+	fmt.Fprintf(w, "window <input name='window' value='%s' size='4' %s>", html.EscapeString(q.Get("window")), input)
+	fmt.Fprintf(w, "sort <input name='sort' value='%s' size='10' %s>", html.EscapeString(q.Get("sort")), input)
+	var names []string
+	for name := range q {
+		switch name {
+		case "author", "repo", "label", "state", "window", "sort":
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range q[name] {
+			fmt.Fprintf(w, "<input type='hidden' name='%s' value='%s'>", html.EscapeString(name), html.EscapeString(v))
+		}
+	}
+	fmt.Fprintf(w, "<input type='submit' value='filter' %s><a href='?' style='color: %s;'>clear</a></form>", input, theme.GridColor)
+}
@@ -0,0 +1,165 @@
+package prmonitor
+
+import (
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		window   string
+		expected time.Duration
+	}{
+		{"3", 72 * time.Hour},
+		{"3d", 72 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"-1", 0},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		got, err := parseWindow(test.window)
+		if got != test.expected || (err != nil) != (test.expected == 0) {
+			t.Logf("ERROR: %q: expected %s, but got %s (%v)", test.window, test.expected, got, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestDashboardQueryFilters(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("state") == "open":
+			w.Write([]byte(`[
+				{"number": 1, "state": "open", "title": "first pr", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "LK4D4"}, "labels": [{"name": "bug"}],
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}},
+				{"number": 2, "state": "open", "title": "second pr", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "stevvooe"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+		case r.URL.Query().Get("state") == "closed":
+			w.Write([]byte(`[
+				{"number": 3, "state": "closed", "title": "third pr", "created_at": "2016-09-20T00:00:00Z",
+				 "closed_at": "2016-09-28T00:00:00Z", "user": {"login": "LK4D4"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	handler := Dashboard(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}, Customization: GetCustomizations()}, client)
+
+	tests := []struct {
+		query    string
+		status   int
+		expected []string
+	}{
+		{"", 200, []string{"first pr", "second pr", "third pr"}},
+		{"?author=LK4D4", 200, []string{"first pr", "third pr"}},
+		{"?author=LK4D4&state=open", 200, []string{"first pr"}},
+		{"?author=lk4d4,LK4D4", 200, []string{"first pr", "third pr"}},
+		{"?label=bug", 200, []string{"first pr"}},
+		{"?repo=docker/swarmkit&window=3d", 200, []string{"first pr", "second pr"}},
+		{"?repo=moby", 200, nil},
+		{"?state=merged", 400, nil},
+		{"?window=soon", 400, nil},
+		{"?window=30d", 400, nil},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/"+test.query, nil)
+		req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+		handler(w, req)
+
+		if w.Code != test.status {
+			t.Logf("ERROR: %s: expected status %d, but got %d", test.query, test.status, w.Code)
+			t.Fail()
+			continue
+		}
+		if w.Code != 200 {
+			continue
+		}
+		body := w.Body.String()
+		for _, title := range []string{"first pr", "second pr", "third pr"} {
+			shown := 0
+			for _, e := range test.expected {
+				if e == title {
+					shown = 1
+				}
+			}
+			if strings.Count(body, title) != shown {
+				t.Logf("ERROR: %s: expected %q shown %d times, but got %d", test.query, title, shown, strings.Count(body, title))
+				t.Fail()
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?author=LK4D4&tz=Europe/London", nil)
+	req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+	handler(w, req)
+	for _, e := range []string{"name='author' value='LK4D4'", "<input type='hidden' name='tz' value='Europe/London'>"} {
+		if !strings.Contains(w.Body.String(), e) {
+			t.Logf("ERROR: expected the filter bar to contain %q", e)
+			t.Fail()
+		}
+	}
+}
+
+func TestDashboardQueryFiltersSaveRequests(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/repos/docker/swarmkit/pulls" && r.URL.Query().Get("state") == "open":
+			w.Write([]byte(`[
+				{"number": 1, "state": "open", "title": "first pr", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "LK4D4"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}},
+				{"number": 2, "state": "open", "title": "second pr", "created_at": "2016-10-01T00:00:00Z",
+				 "user": {"login": "stevvooe"},
+				 "base": {"repo": {"name": "swarmkit", "owner": {"login": "docker"}}}}
+			]`))
+		case strings.HasPrefix(r.URL.Path, "/repos/docker/swarmkit/pulls/"):
+			w.Write([]byte(`{"additions": 1, "deletions": 1, "changed_files": 1, "commits": 1}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	handler := Dashboard(Config{
+		Repos:         []Repo{{Owner: "docker", Repo: "swarmkit"}, {Owner: "docker", Repo: "docker"}},
+		TrackSize:     true,
+		Concurrency:   1,
+		Customization: GetCustomizations(),
+	}, client)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?repo=swarmkit&author=stevvooe", nil)
+	req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+	handler(w, req)
+	if !strings.Contains(w.Body.String(), "second pr") {
+		t.Logf("ERROR: expected the filtered PR to be shown")
+		t.Fail()
+	}
+	for _, r := range requests {
+		if strings.HasPrefix(r, "/repos/docker/docker/") || r == "/repos/docker/swarmkit/pulls/1" {
+			t.Logf("ERROR: unexpected request %s for a repo or PR the query filters out", r)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package prmonitor
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter tracks the github API quota reported on every response
+// and paces requests so a dashboard doesn't exhaust it. Requests that
+// hit a secondary rate limit or a transient server error are retried.
+type RateLimiter struct {
+	// Reserve is the number of remaining requests below which
+	// retrieval slows down, spreading what's left until the reset.
+	Reserve int
+
+	// MaxWait caps any single pause, so a page load can't hang until
+	// the quota resets.
+	MaxWait time.Duration
+
+	// Retries is how many times a failed request is retried.
+	Retries int
+
+	mu   sync.Mutex
+	rate github.Rate
+
+	// replaced in tests
+	clock func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewRateLimiter creates a RateLimiter with sensible defaults.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		Reserve: 100,
+		MaxWait: 30 * time.Second,
+		Retries: 3,
+		clock:   time.Now,
+		sleep:   sleep,
+	}
+}
+
+// sleep pauses for d, returning early with an error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Rate returns the most recent quota reported by github. It is the zero
+// Rate until a response has been seen.
+func (l *RateLimiter) Rate() github.Rate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Do calls f, which should make one github API request, pausing first
+// if the quota is nearly used up and retrying if f fails with a rate
+// limit or 5xx error. Pauses are cut short when ctx is done.
+func (l *RateLimiter) Do(ctx context.Context, f func() (*github.Response, error)) error {
+	for attempt := 0; ; attempt++ {
+		if err := l.sleep(ctx, l.delay()); err != nil {
+			return err
+		}
+		resp, err := f()
+		if resp != nil && resp.Rate.Limit > 0 {
+			l.mu.Lock()
+			l.rate = resp.Rate
+			l.mu.Unlock()
+		}
+		if err == nil {
+			return nil
+		}
+		wait, ok := l.retryAfter(err, attempt)
+		if !ok || attempt >= l.Retries {
+			return err
+		}
+		if err := l.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// delay is how long to wait before the next request. Once remaining
+// quota drops below Reserve, the time left until the reset is shared
+// evenly between the remaining requests.
+func (l *RateLimiter) delay() time.Duration {
+	rate := l.Rate()
+	if rate.Limit == 0 || rate.Remaining >= l.Reserve {
+		return 0
+	}
+	untilReset := rate.Reset.Sub(l.clock())
+	if untilReset <= 0 {
+		return 0
+	}
+	d := untilReset / time.Duration(rate.Remaining+1)
+	if d > l.MaxWait {
+		d = l.MaxWait
+	}
+	return d
+}
+
+// retryAfter decides whether err is worth retrying and how long to
+// wait before doing so.
+func (l *RateLimiter) retryAfter(err error, attempt int) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter, *e.RetryAfter <= l.MaxWait
+		}
+		return backoff(attempt), true
+	case *github.RateLimitError:
+		wait := e.Rate.Reset.Sub(l.clock())
+		return wait, wait <= l.MaxWait
+	case *github.ErrorResponse:
+		if e.Response == nil {
+			return 0, false
+		}
+		// secondary rate limits aren't always recognized as abuse
+		// errors, and can also come back as 429s
+		status := e.Response.StatusCode
+		if s := e.Response.Header.Get("Retry-After"); (status == http.StatusForbidden || status == http.StatusTooManyRequests) && s != "" {
+			seconds, _ := strconv.Atoi(s)
+			wait := time.Duration(seconds) * time.Second
+			return wait, wait <= l.MaxWait
+		}
+		if status == http.StatusTooManyRequests || status >= 500 {
+			return backoff(attempt), true
+		}
+	}
+	return 0, false
+}
+
+// backoff returns an exponentially growing delay with full jitter, so
+// concurrent retries don't arrive at github in lockstep.
+func backoff(attempt int) time.Duration {
+	max := time.Duration(500<<uint(attempt)) * time.Millisecond
+	return time.Duration(rand.Int63n(int64(max)))
+}
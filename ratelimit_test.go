@@ -0,0 +1,153 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testLimiter returns a limiter that records its pauses instead of
+// sleeping.
+func testLimiter(now time.Time, slept *[]time.Duration) *RateLimiter {
+	l := NewRateLimiter()
+	l.clock = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			*slept = append(*slept, d)
+		}
+		return ctx.Err()
+	}
+	return l
+}
+
+// failingServer answers with the given responses in order, then
+// succeeds with the provided rate limit headers.
+func failingServer(failures []func(w http.ResponseWriter), remaining int, reset time.Time) (*httptest.Server, *github.Client, *int) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= len(failures) {
+			failures[calls-1](w)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+		w.Write([]byte("[]"))
+	}))
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return s, client, &calls
+}
+
+func listPulls(client *github.Client) func() (*github.Response, error) {
+	return func() (*github.Response, error) {
+		_, resp, err := client.PullRequests.List(context.Background(), "brentdrich", "prmonitor", nil)
+		return resp, err
+	}
+}
+
+func TestRateLimiterRetriesServerErrors(t *testing.T) {
+	now := time.Now()
+	var slept []time.Duration
+	l := testLimiter(now, &slept)
+	unavailable := func(w http.ResponseWriter) { w.WriteHeader(502) }
+	s, client, calls := failingServer([]func(w http.ResponseWriter){unavailable, unavailable}, 4000, now.Add(time.Hour))
+	defer s.Close()
+
+	if err := l.Do(context.Background(), listPulls(client)); err != nil {
+		t.Logf("ERROR: expected request to succeed after retries, but got %s", err)
+		t.Fail()
+	}
+	if *calls != 3 {
+		t.Logf("ERROR: expected %d calls, but got %d", 3, *calls)
+		t.Fail()
+	}
+	if l.Rate().Remaining != 4000 || l.Rate().Limit != 5000 {
+		t.Logf("ERROR: expected quota to be tracked, but got %+v", l.Rate())
+		t.Fail()
+	}
+}
+
+func TestRateLimiterGivesUp(t *testing.T) {
+	now := time.Now()
+	var slept []time.Duration
+	l := testLimiter(now, &slept)
+	unavailable := func(w http.ResponseWriter) { w.WriteHeader(500) }
+	s, client, calls := failingServer([]func(w http.ResponseWriter){unavailable, unavailable, unavailable, unavailable, unavailable}, 4000, now)
+	defer s.Close()
+
+	if err := l.Do(context.Background(), listPulls(client)); err == nil {
+		t.Logf("ERROR: expected request to fail after %d retries", l.Retries)
+		t.Fail()
+	}
+	if *calls != l.Retries+1 {
+		t.Logf("ERROR: expected %d calls, but got %d", l.Retries+1, *calls)
+		t.Fail()
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	now := time.Now()
+	var slept []time.Duration
+	l := testLimiter(now, &slept)
+	secondary := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(403)
+		w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+	}
+	s, client, _ := failingServer([]func(w http.ResponseWriter){secondary}, 4000, now)
+	defer s.Close()
+
+	if err := l.Do(context.Background(), listPulls(client)); err != nil {
+		t.Logf("ERROR: expected request to succeed after waiting, but got %s", err)
+		t.Fail()
+	}
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Logf("ERROR: expected a single 7s pause, but got %v", slept)
+		t.Fail()
+	}
+}
+
+func TestRateLimiterTooManyRequests(t *testing.T) {
+	now := time.Now()
+	var slept []time.Duration
+	l := testLimiter(now, &slept)
+	secondary := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(429)
+		w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+	}
+	s, client, calls := failingServer([]func(w http.ResponseWriter){secondary}, 4000, now)
+	defer s.Close()
+
+	if err := l.Do(context.Background(), listPulls(client)); err != nil {
+		t.Logf("ERROR: expected request to succeed after waiting, but got %s", err)
+		t.Fail()
+	}
+	if *calls != 2 || len(slept) != 1 || slept[0] != 5*time.Second {
+		t.Logf("ERROR: expected a single 5s pause and a retry, but got %v after %d calls", slept, *calls)
+		t.Fail()
+	}
+}
+
+func TestRateLimiterSlowsDownNearLimit(t *testing.T) {
+	// reset times only have second precision
+	now := time.Unix(time.Now().Unix(), 0)
+	var slept []time.Duration
+	l := testLimiter(now, &slept)
+	s, client, _ := failingServer(nil, 9, now.Add(100*time.Second))
+	defer s.Close()
+
+	l.Do(context.Background(), listPulls(client))
+	l.Do(context.Background(), listPulls(client))
+	if len(slept) != 1 || slept[0] != 10*time.Second {
+		t.Logf("ERROR: expected remaining time to be spread over 10 requests, but got %v", slept)
+		t.Fail()
+	}
+}
@@ -0,0 +1,140 @@
+package prmonitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"io"
+	"time"
+)
+
+// sizeBuckets are the size badges shown on PRs, smallest first, with
+// the most lines a PR in each bucket may change.
+var sizeBuckets = []struct {
+	Name  string
+	Lines int
+}{
+	{"XS", 10},
+	{"S", 100},
+	{"M", 500},
+	{"L", 1000},
+	{"XL", -1},
+}
+
+// sizeBucket returns the size badge of a PR by the number of lines it
+// changes, or "" if its size isn't known.
+func sizeBucket(pr SummarizedPullRequest) string {
+	if pr.Commits == 0 {
+		return ""
+	}
+	lines := pr.Additions + pr.Deletions
+	for _, b := range sizeBuckets {
+		if b.Lines < 0 || lines <= b.Lines {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+// forSize scales the thresholds of c by its SizeScale for bucket, so
+// large PRs can be given longer before they are flagged.
+func (c Customization) forSize(bucket string) Customization {
+	if scale, ok := c.SizeScale[bucket]; ok && scale > 0 {
+		c.PassiveTime *= scale
+		c.WarningTime *= scale
+		tiers := make([]Tier, len(c.Tiers))
+		for i, t := range c.Tiers {
+			t.After *= scale
+			tiers[i] = t
+		}
+		c.Tiers = tiers
+	}
+	return c
+}
+
+// TrackSize looks up how many lines, files and commits each pull
+// request changes. The REST API only includes these when fetching PRs
+// one at a time, so this costs a request per PR; the GraphQL API always
+// includes them. PRs whose size can't be fetched are passed on
+// unchanged and reported to diag.
+func TrackSize(ctx context.Context, in <-chan SummarizedPullRequest, client *github.Client, limiter *RateLimiter, diag *Diagnostics) <-chan SummarizedPullRequest {
+	out := make(chan SummarizedPullRequest)
+	go func() {
+		for v := range in {
+			if v.Commits > 0 || v.Grouped > 0 {
+				out <- v
+				continue
+			}
+			var pr *github.PullRequest
+			err := limiter.Do(ctx, func() (*github.Response, error) {
+				var resp *github.Response
+				var err error
+				pr, resp, err = client.PullRequests.Get(ctx, v.Owner, v.Repo, v.Number)
+				return resp, err
+			})
+			if err != nil {
+				diag.Report("%s/%s#%d: couldn't fetch size: %s", v.Owner, v.Repo, v.Number, err)
+				out <- v
+				continue
+			}
+			v.Additions = pr.GetAdditions()
+			v.Deletions = pr.GetDeletions()
+			v.ChangedFiles = pr.GetChangedFiles()
+			v.Commits = pr.GetCommits()
+			out <- v
+		}
+		close(out)
+	}()
+	return out
+}
+
+// sizeBadge renders the size bucket of a PR, with its exact size as a
+// tooltip.
+func sizeBadge(pr SummarizedPullRequest) string {
+	bucket := sizeBucket(pr)
+	if bucket == "" {
+		return ""
+	}
+	return fmt.Sprintf("<span title='+%d -%d in %d files, %d commits' style='background: rgba(128, 128, 128, 0.4); border-radius: 3px; padding: 0 0.3em; margin-right: 0.4em; font-size: small;'>%s</span>",
+		pr.Additions, pr.Deletions, pr.ChangedFiles, pr.Commits, bucket)
+}
+
+// sizeStat is how long the PRs in one size bucket took to review.
+type sizeStat struct {
+	Bucket string
+	Count  int
+	Median time.Duration
+}
+
+// sizeBreakdown works out the median time PRs of each size spent open,
+// in working hours according to cal. Buckets without PRs are left out.
+func sizeBreakdown(prs SummarizedPullRequests, cal *Calendar) []sizeStat {
+	durations := map[string][]float64{}
+	for _, pr := range prs {
+		if bucket := sizeBucket(pr); bucket != "" && pr.Grouped == 0 {
+			durations[bucket] = append(durations[bucket], float64(workingDuration(pr, cal)))
+		}
+	}
+	var stats []sizeStat
+	for _, b := range sizeBuckets {
+		d := durations[b.Name]
+		if len(d) == 0 {
+			continue
+		}
+		stats = append(stats, sizeStat{Bucket: b.Name, Count: len(d), Median: time.Duration(median(d))})
+	}
+	return stats
+}
+
+// displaySizeBreakdown writes a table of review time by PR size.
+func displaySizeBreakdown(w io.Writer, prs SummarizedPullRequests, config Config) {
+	stats := sizeBreakdown(prs, config.Calendar)
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "<table style='color: %s; font-size: small; margin-top: 1em;'><tr><th>size</th><th>pull requests</th><th>median time open</th></tr>", config.Customization.GridColor)
+	for _, s := range stats {
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%d</td><td>%s</td></tr>", s.Bucket, s.Count, s.Median/time.Minute*time.Minute)
+	}
+	fmt.Fprintf(w, "</table>")
+}
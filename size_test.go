@@ -0,0 +1,105 @@
+package prmonitor
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSizeBucket(t *testing.T) {
+	tests := []struct {
+		pr       SummarizedPullRequest
+		expected string
+	}{
+		{SummarizedPullRequest{}, ""},
+		{SummarizedPullRequest{Additions: 1, Deletions: 1, Commits: 1}, "XS"},
+		{SummarizedPullRequest{Additions: 60, Deletions: 40, Commits: 2}, "S"},
+		{SummarizedPullRequest{Additions: 400, Commits: 3}, "M"},
+		{SummarizedPullRequest{Additions: 900, Deletions: 100, Commits: 3}, "L"},
+		{SummarizedPullRequest{Additions: 2000, Commits: 12}, "XL"},
+	}
+	for _, test := range tests {
+		if got := sizeBucket(test.pr); got != test.expected {
+			t.Logf("ERROR: +%d -%d: expected %q, but got %q", test.pr.Additions, test.pr.Deletions, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestForSize(t *testing.T) {
+	c := GetCustomizations()
+	c.SizeScale = map[string]float64{"XL": 3}
+	if got := c.forSize("XL"); got.PassiveTime != 72 || got.WarningTime != 144 {
+		t.Logf("ERROR: expected XL thresholds to be tripled, but got %v and %v", got.PassiveTime, got.WarningTime)
+		t.Fail()
+	}
+	if got := c.forSize("S"); got.PassiveTime != 24 || got.WarningTime != 48 {
+		t.Logf("ERROR: expected S thresholds to be unchanged, but got %v and %v", got.PassiveTime, got.WarningTime)
+		t.Fail()
+	}
+}
+
+func TestTrackSize(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/brentdrich/prmonitor/pulls/1":
+			w.Write([]byte(`{"number": 1, "additions": 120, "deletions": 30, "changed_files": 4, "commits": 2}`))
+		default:
+			t.Logf("ERROR: unexpected request %s", r.URL.Path)
+			t.Fail()
+			w.WriteHeader(404)
+		}
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	in := make(chan SummarizedPullRequest)
+	out := TrackSize(context.Background(), in, client, NewRateLimiter(), nil)
+	go func() {
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 1}
+		// already sized by the GraphQL API
+		in <- SummarizedPullRequest{Owner: "brentdrich", Repo: "prmonitor", Number: 2, Additions: 5, Commits: 1}
+		close(in)
+	}()
+
+	for pr := range out {
+		switch pr.Number {
+		case 1:
+			if pr.Additions != 120 || pr.Deletions != 30 || pr.ChangedFiles != 4 || pr.Commits != 2 || sizeBucket(pr) != "M" {
+				t.Logf("ERROR: unexpected size %+v", pr)
+				t.Fail()
+			}
+		case 2:
+			if pr.Additions != 5 {
+				t.Logf("ERROR: expected the known size to be kept, but got %+v", pr)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestSizeBreakdown(t *testing.T) {
+	opened := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	pr := func(lines int, hours int) SummarizedPullRequest {
+		return SummarizedPullRequest{Additions: lines, Commits: 1, OpenedAt: opened, ClosedAt: opened.Add(time.Duration(hours) * time.Hour)}
+	}
+	stats := sizeBreakdown(SummarizedPullRequests{pr(5, 1), pr(5, 3), pr(2000, 50), pr(2000, 70), pr(2000, 90), {}}, nil)
+	if len(stats) != 2 {
+		t.Logf("ERROR: expected two buckets, but got %+v", stats)
+		t.Fail()
+		return
+	}
+	if stats[0].Bucket != "XS" || stats[0].Count != 2 || stats[0].Median != 2*time.Hour {
+		t.Logf("ERROR: unexpected XS stats %+v", stats[0])
+		t.Fail()
+	}
+	if stats[1].Bucket != "XL" || stats[1].Count != 3 || stats[1].Median != 70*time.Hour {
+		t.Logf("ERROR: unexpected XL stats %+v", stats[1])
+		t.Fail()
+	}
+}
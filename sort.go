@@ -0,0 +1,115 @@
+package prmonitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sortKeys compare two pull requests by a single key, returning a
+// negative number if a comes first, a positive one if b does, and zero
+// if they tie.
+var sortKeys = map[string]func(a, b SummarizedPullRequest) int{
+	"date": func(a, b SummarizedPullRequest) int {
+		if c := compareTimes(b.ClosedAt, a.ClosedAt); c != 0 {
+			return c
+		}
+		return compareTimes(b.OpenedAt, a.OpenedAt)
+	},
+	"repo": func(a, b SummarizedPullRequest) int {
+		return strings.Compare(a.Owner+"/"+a.Repo, b.Owner+"/"+b.Repo)
+	},
+	"age": func(a, b SummarizedPullRequest) int {
+		return compareInts(int64(openDuration(b)), int64(openDuration(a)))
+	},
+	"author": func(a, b SummarizedPullRequest) int {
+		return strings.Compare(strings.ToLower(a.Author), strings.ToLower(b.Author))
+	},
+	"size": func(a, b SummarizedPullRequest) int {
+		// biggest first, PRs of unknown size last
+		return compareInts(prSize(b), prSize(a))
+	},
+	"updated": func(a, b SummarizedPullRequest) int {
+		return compareTimes(b.UpdatedAt, a.UpdatedAt)
+	},
+	"review": func(a, b SummarizedPullRequest) int {
+		return compareInts(int64(reviewStateOrder(a.ReviewState)), int64(reviewStateOrder(b.ReviewState)))
+	},
+}
+
+// reviewStateOrder puts the PRs waiting on reviewers first, then those
+// waiting on their authors, then approved ones.
+func reviewStateOrder(state string) int {
+	switch state {
+	case "review_required":
+		return 0
+	case "changes_requested":
+		return 1
+	case "approved":
+		return 2
+	}
+	return 3
+}
+
+// prSize is the number of lines a PR changes, or -1 if that isn't known.
+func prSize(pr SummarizedPullRequest) int64 {
+	if pr.Commits == 0 {
+		return -1
+	}
+	return int64(pr.Additions + pr.Deletions)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// Keys splits a SortBy into its keys, returning an error for any that
+// aren't known.
+func (s SortBy) Keys() ([]string, error) {
+	var keys []string
+	for _, k := range strings.Split(string(s), ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			continue
+		}
+		if _, ok := sortKeys[k]; !ok {
+			return nil, fmt.Errorf("unknown sort %q", k)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// ByKeys sorts summarized pull requests by each of Keys in turn, and
+// then by date.
+type ByKeys struct {
+	SummarizedPullRequests
+	Keys []string
+}
+
+// Less compares two pull request indices
+func (a ByKeys) Less(i, j int) bool {
+	pri, prj := a.SummarizedPullRequests[i], a.SummarizedPullRequests[j]
+	for _, k := range a.Keys {
+		if c := sortKeys[k](pri, prj); c != 0 {
+			return c < 0
+		}
+	}
+	return sortKeys["date"](pri, prj) < 0
+}
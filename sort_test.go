@@ -0,0 +1,52 @@
+package prmonitor
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSortByKeys(t *testing.T) {
+	now := time.Now()
+	prs := SummarizedPullRequests{
+		{Repo: "b", Number: 1, Author: "zed", OpenedAt: now.Add(-5 * time.Hour), ClosedAt: now, UpdatedAt: now.Add(-4 * time.Hour), Additions: 10, Commits: 1, ReviewState: "approved"},
+		{Repo: "a", Number: 2, Author: "amy", OpenedAt: now.Add(-1 * time.Hour), ClosedAt: now, UpdatedAt: now.Add(-1 * time.Hour), Additions: 900, Commits: 4, ReviewState: "changes_requested"},
+		{Repo: "b", Number: 3, Author: "Bob", OpenedAt: now.Add(-9 * time.Hour), ClosedAt: now, UpdatedAt: now.Add(-8 * time.Hour), ReviewState: "review_required"},
+		{Repo: "a", Number: 4, Author: "amy", OpenedAt: now.Add(-3 * time.Hour), ClosedAt: now, UpdatedAt: now.Add(-2 * time.Hour), Additions: 50, Commits: 1},
+	}
+	tests := []struct {
+		sortBy   SortBy
+		expected string
+	}{
+		{"", "[2 4 1 3]"},
+		{"date", "[2 4 1 3]"},
+		{"age", "[3 1 4 2]"},
+		{"repo,age", "[4 2 3 1]"},
+		{"author, age", "[4 2 3 1]"},
+		{"size", "[2 4 1 3]"},
+		{"updated", "[2 4 1 3]"},
+		{"review", "[3 2 1 4]"},
+	}
+	for _, test := range tests {
+		keys, err := test.sortBy.Keys()
+		if err != nil {
+			panic(err)
+		}
+		sorted := append(SummarizedPullRequests{}, prs...)
+		sort.Sort(ByKeys{sorted, keys})
+		var got []int
+		for _, pr := range sorted {
+			got = append(got, pr.Number)
+		}
+		if fmt.Sprint(got) != test.expected {
+			t.Logf("ERROR: sorting by %q: expected %s, but got %v", test.sortBy, test.expected, got)
+			t.Fail()
+		}
+	}
+
+	if _, err := SortBy("repo,stars").Keys(); err == nil {
+		t.Logf("ERROR: expected an error for an unknown sort key")
+		t.Fail()
+	}
+}
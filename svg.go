@@ -0,0 +1,135 @@
+package prmonitor
+
+import (
+	"fmt"
+	"github.com/google/go-github/github"
+	"html"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sizes of the parts of an SVG chart, in pixels.
+const (
+	svgWidth     = 1000
+	svgTitle     = 40
+	svgLegend    = 24
+	svgAxis      = 22
+	svgRow       = 22
+	svgGroup     = 28
+	svgBarHeight = 18
+)
+
+// ChartSVG responds to an http request with the dashboard drawn as a
+// standalone SVG image, for embedding in pages that can't show the
+// html dashboard. It accepts the same query parameters.
+func ChartSVG(t Config, client *github.Client) http.HandlerFunc {
+	d := newDashboard(client)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		d.serve(w, r, t, SVG)
+	}
+}
+
+// SVG draws pull requests as a Gantt chart in an SVG image, once
+// they have all come in from the rest of the pipeline. It takes the
+// same arguments as Display, but shows neither the quota nor problems.
+func SVG(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics) <-chan bool {
+	out := make(chan bool)
+	go func() {
+		config.Customization = config.Customization.WithDefaults()
+		theme := config.Customization
+		axis := newChartAxis(now, config)
+		var prs SummarizedPullRequests
+		for pr := range in {
+			prs = append(prs, pr)
+		}
+		sortPRs(prs, sortBy, diag)
+		groups := groupPRs(prs, config)
+
+		top := svgTitle + svgLegend + svgAxis
+		height := top + len(prs)*svgRow + 10
+		if config.GroupBy != "" {
+			height = top + 10
+			for _, g := range groups {
+				height += svgGroup + len(g.PRs)*svgRow
+			}
+		}
+		x := func(t time.Time) float64 {
+			p := axis.Position(t)
+			if p < 0 {
+				p = 0
+			} else if p > 1 {
+				p = 1
+			}
+			return p * svgWidth
+		}
+
+		font := theme.Font
+		if font == "" {
+			font = "sans-serif"
+		}
+		fmt.Fprintf(w, "<svg xmlns='http://www.w3.org/2000/svg' xmlns:xlink='http://www.w3.org/1999/xlink' width='%d' height='%d' viewBox='0 0 %d %d' font-family='%s' font-size='13'>",
+			svgWidth, height, svgWidth, height, html.EscapeString(font))
+		fmt.Fprintf(w, "<rect width='100%%' height='100%%' fill='%s'/>", html.EscapeString(theme.BackgroundColor))
+		fmt.Fprintf(w, "<text x='0' y='28' font-size='24' font-weight='bold' fill='%s'>%s</text>", html.EscapeString(theme.TextColor), html.EscapeString(chartTitle(config)))
+
+		// legend
+		lx := 0
+		legend := append(theme.tiers(), Tier{Name: "closed", Color: theme.ClosedColor})
+		for i, t := range legend {
+			name := t.Name
+			if t.Name != "closed" {
+				name += " " + tierRange(legend[:len(legend)-1], i)
+			}
+			fmt.Fprintf(w, "<rect x='%d' y='%d' width='12' height='12' fill='%s'/><text x='%d' y='%d' fill='%s' font-size='11'>%s</text>",
+				lx, svgTitle+2, html.EscapeString(t.Color), lx+16, svgTitle+12, html.EscapeString(theme.GridColor), html.EscapeString(name))
+			lx += 24 + 7*len(name)
+		}
+
+		// axis, with days off shaded behind the grid lines
+		for _, p := range axis.DaysOff {
+			fmt.Fprintf(w, "<rect x='%.2f' y='%d' width='%.2f' height='%d' fill='rgb(128, 128, 128)' fill-opacity='0.15'/>", x(p.from), svgTitle+svgLegend, x(p.to)-x(p.from), height-svgTitle-svgLegend)
+		}
+		for i, label := range axis.Labels() {
+			fmt.Fprintf(w, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='%s'/>", i*svgWidth/10, svgTitle+svgLegend, i*svgWidth/10, height, html.EscapeString(theme.GridColor))
+			fmt.Fprintf(w, "<text x='%d' y='%d' text-anchor='middle' fill='%s' font-size='11'>%s</text>", i*svgWidth/10+svgWidth/20, svgTitle+svgLegend+15, html.EscapeString(theme.GridColor), label)
+		}
+
+		y := top
+		for _, g := range groups {
+			if config.GroupBy != "" {
+				open, age := groupSummary(g, config)
+				fmt.Fprintf(w, "<text x='0' y='%d' fill='%s' font-weight='bold'>%s <tspan fill='%s' font-weight='normal' font-size='11'>%d open, median age %s</tspan></text>",
+					y+20, html.EscapeString(theme.TextColor), html.EscapeString(g.Name), html.EscapeString(theme.GridColor), open, age/time.Minute*time.Minute)
+				y += svgGroup
+			}
+			for _, pr := range g.PRs {
+				bar := newChartBar(pr, config, g.Name)
+				label := fmt.Sprintf("%s/%s #%d %s by %s", pr.Owner, pr.Repo, pr.Number, pr.Title, pr.Author)
+				if pr.Grouped > 0 {
+					label = fmt.Sprintf("%s/%s %s", pr.Owner, pr.Repo, pr.Title)
+				}
+				if pr.URL != "" {
+					fmt.Fprintf(w, "<a href='%s' xlink:href='%s' target='_blank'>", html.EscapeString(pr.URL), html.EscapeString(pr.URL))
+				}
+				fmt.Fprintf(w, "<g><title>%s</title>", html.EscapeString(tooltip(pr, axis.Location, bar.Age, bar.Tier)))
+				for _, s := range bar.Segments {
+					if s.to.Before(axis.Start) || s.from.After(axis.End) {
+						continue
+					}
+					fmt.Fprintf(w, "<rect x='%.2f' y='%d' width='%.2f' height='%d' fill='%s'/>", x(s.from), y+2, x(s.to)-x(s.from), svgBarHeight, html.EscapeString(bar.Color))
+				}
+				fmt.Fprintf(w, "<text x='4' y='%d' fill='%s'>%s</text></g>", y+16, html.EscapeString(theme.TextColor), html.EscapeString(label))
+				if pr.URL != "" {
+					fmt.Fprintf(w, "</a>")
+				}
+				y += svgRow
+			}
+		}
+		fmt.Fprintf(w, "</svg>")
+		out <- true
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,74 @@
+package prmonitor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/google/go-github/github"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSVG(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 2)
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "old <pr> & co", URL: "https://github.com/docker/swarmkit/pull/1",
+		Author: "LK4D4", OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"}
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 2, Title: "ancient pr", Author: "LK4D4",
+		OpenedAt: now.Add(-1000 * time.Hour), ClosedAt: now.Add(-300 * time.Hour), State: "closed"}
+	close(in)
+
+	var w bytes.Buffer
+	<-SVG(in, &w, now, SortBy("date"), Config{Customization: GetCustomizations()}, nil, nil)
+
+	// the chart must be well formed xml to be embedded
+	d := xml.NewDecoder(bytes.NewReader(w.Bytes()))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Logf("ERROR: invalid svg: %s", err)
+			t.Fail()
+			break
+		}
+	}
+	for _, e := range []string{
+		"<rect x='650.00' y='88' width='300.00' height='18' fill='#cc0000'/>",
+		"xlink:href='https://github.com/docker/swarmkit/pull/1'",
+		"docker/swarmkit #1 old &lt;pr&gt; &amp; co by LK4D4",
+		">today</text>",
+	} {
+		if !strings.Contains(w.String(), e) {
+			t.Logf("ERROR: expected %q in %s", e, w.String())
+			t.Fail()
+		}
+	}
+	if strings.Contains(w.String(), "height='18' fill='#999'/>") {
+		t.Logf("ERROR: expected no bar for a PR closed before the axis starts")
+		t.Fail()
+	}
+}
+
+func TestChartSVG(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer s.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/chart.svg", nil)
+	req.Header.Set("X-Timestamp", "2016-10-03T00:00:00Z")
+	ChartSVG(Config{Repos: []Repo{{Owner: "docker", Repo: "swarmkit"}}}, client)(w, req)
+	if w.Header().Get("Content-Type") != "image/svg+xml" || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Logf("ERROR: expected an svg image, but got %s %s", w.Header().Get("Content-Type"), w.Body.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,73 @@
+package prmonitor
+
+import (
+	"html"
+	"strings"
+)
+
+// themes are the built-in page colors, selected with
+// Customization.Theme.
+var themes = map[string]Customization{
+	"dark": {
+		BackgroundColor: "#333",
+		TextColor:       "#fff",
+		GridColor:       "#999",
+	},
+	"light": {
+		BackgroundColor: "#fafafa",
+		TextColor:       "#222",
+		GridColor:       "#777",
+	},
+}
+
+// palettes are the built-in tier colors, selected with
+// Customization.Palette. "colorblind" uses the Okabe-Ito colors and
+// "tol" Paul Tol's bright scheme, which can both be told apart with
+// the common forms of color blindness.
+var palettes = map[string]Customization{
+	"default": GetCustomizations(),
+	"colorblind": {
+		PassiveColor: "#56b4e9",
+		WarningColor: "#f0e442",
+		AlertColor:   "#d55e00",
+		ClosedColor:  "#999",
+	},
+	"tol": {
+		PassiveColor: "#4477aa",
+		WarningColor: "#ccbb44",
+		AlertColor:   "#ee6677",
+		ClosedColor:  "#bbb",
+	},
+}
+
+// tierIcons are drawn on each bar with Customization.Icons, in tier
+// order, so tiers can be told apart by shape as well as color.
+var tierIcons = []string{"&#9679;", "&#9650;", "&#9632;", "&#9670;", "&#9733;", "&#9660;"}
+
+// WithDefaults fills in the fields of c that aren't set from its theme
+// and palette, and then from GetCustomizations. Unknown themes and
+// palettes fall back to "dark" and "default".
+func (c Customization) WithDefaults() Customization {
+	theme, ok := themes[strings.ToLower(c.Theme)]
+	if !ok {
+		theme = themes["dark"]
+	}
+	palette, ok := palettes[strings.ToLower(c.Palette)]
+	if !ok {
+		palette = palettes["default"]
+	}
+	return c.withFallback(palette).withFallback(theme).withFallback(GetCustomizations())
+}
+
+// tierIcon returns the shape drawn for tier, if icons are turned on.
+func tierIcon(c Customization, tier string) string {
+	if !c.Icons {
+		return ""
+	}
+	for i, t := range c.tiers() {
+		if t.Name == tier && i < len(tierIcons) {
+			return "<span title='" + html.EscapeString(tier) + "' style='margin-right: 0.3em;'>" + tierIcons[i] + "</span>"
+		}
+	}
+	return ""
+}
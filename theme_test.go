@@ -0,0 +1,50 @@
+package prmonitor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithDefaults(t *testing.T) {
+	c := Customization{Theme: "light", Palette: "colorblind", AlertColor: "#ff00ff", WarningTime: 12}.WithDefaults()
+	tests := []struct {
+		field, got, expected string
+	}{
+		{"background", c.BackgroundColor, "#fafafa"},
+		{"passive", c.PassiveColor, "#56b4e9"},
+		{"alert", c.AlertColor, "#ff00ff"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Logf("ERROR: expected %s color %s, but got %s", test.field, test.expected, test.got)
+			t.Fail()
+		}
+	}
+	if c.PassiveTime != 24 || c.WarningTime != 12 {
+		t.Logf("ERROR: expected default passive time and configured warning time, but got %v and %v", c.PassiveTime, c.WarningTime)
+		t.Fail()
+	}
+
+	if d := (Customization{Theme: "neon"}).WithDefaults(); d.BackgroundColor != "#333" || d.PassiveColor != "#00cc66" {
+		t.Logf("ERROR: expected an unknown theme to fall back to dark, but got %+v", d)
+		t.Fail()
+	}
+}
+
+func TestDisplayTheme(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	in := make(chan SummarizedPullRequest, 1)
+	in <- SummarizedPullRequest{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "old pr", OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"}
+	close(in)
+
+	var w bytes.Buffer
+	<-Display(in, &w, now, SortBy("date"), Config{Customization: Customization{Theme: "light", Font: "sans-serif", Icons: true}}, nil, nil)
+	for _, e := range []string{"background: #fafafa; color: #222; font-family: sans-serif;", "<span title='alert' style='margin-right: 0.3em;'>&#9632;</span>"} {
+		if !strings.Contains(w.String(), e) {
+			t.Logf("ERROR: expected %q in %s", e, w.String())
+			t.Fail()
+		}
+	}
+}
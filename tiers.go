@@ -0,0 +1,121 @@
+package prmonitor
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tier is a color given to open PRs once they have been waiting for
+// After hours.
+type Tier struct {
+	Name  string
+	After float64
+	Color string
+}
+
+// byAfter sorts tiers from youngest to oldest.
+type byAfter []Tier
+
+func (a byAfter) Len() int           { return len(a) }
+func (a byAfter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAfter) Less(i, j int) bool { return a[i].After < a[j].After }
+
+// tiers returns the tiers of c in order, either as configured or made
+// from the passive, warning and alert shorthand.
+func (c Customization) tiers() []Tier {
+	if len(c.Tiers) > 0 {
+		tiers := append([]Tier{}, c.Tiers...)
+		sort.Stable(byAfter(tiers))
+		return tiers
+	}
+	return []Tier{
+		{Name: "passive", After: 0, Color: c.PassiveColor},
+		{Name: "warning", After: c.PassiveTime, Color: c.WarningColor},
+		{Name: "alert", After: c.WarningTime, Color: c.AlertColor},
+	}
+}
+
+// tierFor returns the index of the tier a PR open for openedFor hours
+// is in.
+func tierFor(tiers []Tier, openedFor float64) int {
+	i := 0
+	for j, t := range tiers {
+		if openedFor >= t.After {
+			i = j
+		}
+	}
+	return i
+}
+
+// gradientColor blends the colors of the tiers either side of
+// openedFor. Colors that aren't hex codes can't be blended, so the
+// tier's own color is used.
+func gradientColor(tiers []Tier, openedFor float64) string {
+	i := tierFor(tiers, openedFor)
+	if i+1 >= len(tiers) || openedFor < tiers[i].After {
+		return tiers[i].Color
+	}
+	from, ok1 := parseHexColor(tiers[i].Color)
+	to, ok2 := parseHexColor(tiers[i+1].Color)
+	if !ok1 || !ok2 {
+		return tiers[i].Color
+	}
+	f := (openedFor - tiers[i].After) / (tiers[i+1].After - tiers[i].After)
+	var mixed [3]float64
+	for k := range mixed {
+		mixed[k] = from[k] + (to[k]-from[k])*f
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(mixed[0]+0.5), int(mixed[1]+0.5), int(mixed[2]+0.5))
+}
+
+// parseHexColor reads a CSS color like #0c6 or #00cc66.
+func parseHexColor(color string) ([3]float64, bool) {
+	var rgb [3]float64
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 || !strings.HasPrefix(color, "#") {
+		return rgb, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb, false
+	}
+	rgb[0], rgb[1], rgb[2] = float64(v>>16&0xff), float64(v>>8&0xff), float64(v&0xff)
+	return rgb, true
+}
+
+// displayLegend writes a key of the colors PRs can be drawn in.
+func displayLegend(w io.Writer, c Customization) {
+	tiers := c.tiers()
+	fmt.Fprintf(w, "<div style='color: %s; font-size: small; margin: 0.5em 0;'>", c.GridColor)
+	if c.Gradient {
+		var colors []string
+		for _, t := range tiers {
+			colors = append(colors, t.Color)
+		}
+		if len(colors) == 1 {
+			colors = append(colors, colors[0])
+		}
+		fmt.Fprintf(w, "<span style='display: inline-block; width: 8em; height: 0.8em; margin-right: 0.6em; background: linear-gradient(90deg, %s);'></span>", strings.Join(colors, ", "))
+	}
+	for i, t := range tiers {
+		fmt.Fprintf(w, "<span style='margin-right: 0.8em;'><span style='display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.3em; background: %s;'></span>%s%s %s</span>",
+			html.EscapeString(t.Color), tierIcon(c, t.Name), html.EscapeString(t.Name), tierRange(tiers, i))
+	}
+	fmt.Fprintf(w, "<span><span style='display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.3em; background: %s;'></span>closed</span>", html.EscapeString(c.ClosedColor))
+	fmt.Fprintf(w, "</div>")
+}
+
+// tierRange describes the ages in tier i, like "24-48h".
+func tierRange(tiers []Tier, i int) string {
+	if i+1 < len(tiers) {
+		return fmt.Sprintf("%g-%gh", tiers[i].After, tiers[i+1].After)
+	}
+	return fmt.Sprintf("%gh+", tiers[i].After)
+}
@@ -0,0 +1,72 @@
+package prmonitor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTiers(t *testing.T) {
+	config := Config{Customization: Customization{Tiers: []Tier{
+		{Name: "stale", After: 72, Color: "#cc0000"},
+		{Name: "fresh", After: 0, Color: "#00cc66"},
+		{Name: "aging", After: 8, Color: "#ffff00"},
+		{Name: "ripe", After: 24, Color: "#ff8800"},
+	}}.WithDefaults()}
+	tests := []struct {
+		hours float64
+		state string
+		tier  string
+		color string
+	}{
+		{1, "open", "fresh", "#00cc66"},
+		{8, "open", "aging", "#ffff00"},
+		{30, "open", "ripe", "#ff8800"},
+		{100, "open", "stale", "#cc0000"},
+		{100, "closed", "closed", "#999"},
+	}
+	for _, test := range tests {
+		if tier, color := getTier(config, test.hours, test.state), getColor(config, test.hours, test.state); tier != test.tier || color != test.color {
+			t.Logf("ERROR: %vh %s: expected %s %s, but got %s %s", test.hours, test.state, test.tier, test.color, tier, color)
+			t.Fail()
+		}
+	}
+
+	// the old fields still work as a shorthand
+	shorthand := Config{Customization: GetCustomizations()}
+	if tier := getTier(shorthand, 30, "open"); tier != "warning" {
+		t.Logf("ERROR: expected the warning tier, but got %s", tier)
+		t.Fail()
+	}
+}
+
+func TestGradientColor(t *testing.T) {
+	tiers := []Tier{{Name: "a", After: 0, Color: "#000"}, {Name: "b", After: 10, Color: "#ffffff"}, {Name: "c", After: 20, Color: "red"}}
+	tests := []struct {
+		hours    float64
+		expected string
+	}{
+		{0, "#000000"},
+		{5, "#808080"},
+		{10, "#ffffff"},
+		{15, "#ffffff"},
+		{30, "red"},
+	}
+	for _, test := range tests {
+		if got := gradientColor(tiers, test.hours); got != test.expected {
+			t.Logf("ERROR: %vh: expected %s, but got %s", test.hours, test.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestDisplayLegend(t *testing.T) {
+	var w bytes.Buffer
+	displayLegend(&w, Customization{Gradient: true}.WithDefaults())
+	for _, e := range []string{"linear-gradient(90deg, #00cc66, #ffff00, #cc0000)", "passive 0-24h", "warning 24-48h", "alert 48h+", "closed"} {
+		if !strings.Contains(w.String(), e) {
+			t.Logf("ERROR: expected %q in %s", e, w.String())
+			t.Fail()
+		}
+	}
+}
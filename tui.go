@@ -0,0 +1,556 @@
+package prmonitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/go-github/github"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TUI shows the dashboard in a terminal, as a Gantt chart drawn with
+// ANSI colors. It expects the terminal to be in raw mode, so that it
+// gets each key as it is pressed.
+type TUI struct {
+	// Refresh is how often pull requests are retrieved again.
+	Refresh time.Duration
+
+	// Size returns the width and height of the terminal.
+	Size func() (width int, height int)
+
+	// Open shows the selected PR's URL, usually in a browser.
+	Open func(url string) error
+
+	// TrueColor draws colors as they are configured, rather than the
+	// nearest of the 256 colors every terminal supports.
+	TrueColor bool
+
+	config Config
+	d      *dashboard
+
+	// the pull requests as last retrieved, and when.
+	prs      SummarizedPullRequests
+	now      time.Time
+	problems []string
+	fetching bool
+	fetched  chan tuiFetch
+
+	// the applied filter, and the one being typed after a "/".
+	filter  string
+	editing bool
+	input   string
+
+	// shown in place of the help line until the next key press.
+	status string
+
+	// the pull requests on screen, in order, and the selected one.
+	shown    SummarizedPullRequests
+	selected int
+	offset   int
+}
+
+// tuiFetch is the result of retrieving pull requests in the
+// background.
+type tuiFetch struct {
+	prs      SummarizedPullRequests
+	now      time.Time
+	problems []string
+}
+
+// NewTUI returns a terminal dashboard of the repos in t, refreshed
+// every five minutes.
+func NewTUI(t Config, client *github.Client) *TUI {
+	return &TUI{
+		Refresh: 5 * time.Minute,
+		Size:    func() (int, int) { return 80, 24 },
+		Open:    func(string) error { return fmt.Errorf("opening links isn't supported") },
+		config:  t,
+		d:       newDashboard(client),
+		fetched: make(chan tuiFetch, 1),
+	}
+}
+
+// Run draws the dashboard to out and handles the keys read from in
+// until q is pressed or in is closed.
+func (u *TUI) Run(in io.Reader, out io.Writer) error {
+	// draw on the alternate screen, so the shell is left as it was
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []byte, 16)
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				keys <- append([]byte{}, buf[:n]...)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	refresh := time.NewTicker(u.Refresh)
+	defer refresh.Stop()
+	u.fetch()
+	var pending []byte
+	for {
+		u.draw(out)
+		select {
+		case b := <-keys:
+			var parsed []string
+			parsed, pending = parseKeys(append(pending, b...))
+			for _, k := range parsed {
+				if !u.key(k) {
+					return nil
+				}
+			}
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case f := <-u.fetched:
+			u.prs, u.now, u.problems, u.fetching = f.prs, f.now, f.problems, false
+		case <-refresh.C:
+			u.fetch()
+		}
+	}
+}
+
+// fetch retrieves the pull requests again in the background, unless
+// that is already happening.
+func (u *TUI) fetch() {
+	if u.fetching {
+		return
+	}
+	u.fetching = true
+	go func() {
+		var f tuiFetch
+		f.now = time.Now()
+		u.d.run(context.Background(), ioutil.Discard, f.now, u.config, func(in <-chan SummarizedPullRequest, w io.Writer, now time.Time, sortBy SortBy, config Config, limiter *RateLimiter, diag *Diagnostics) <-chan bool {
+			out := make(chan bool)
+			go func() {
+				for pr := range in {
+					f.prs = append(f.prs, pr)
+				}
+				f.problems = diag.Problems()
+				out <- true
+				close(out)
+			}()
+			return out
+		})
+		u.fetched <- f
+	}()
+}
+
+// parseKeys names the keys in a read from a raw terminal: "up",
+// "down", "pgup", "pgdn", "home", "end", "enter", "esc", "backspace",
+// "ctrl-c", "ctrl-l", or the character typed. An escape sequence cut
+// off by the end of the read is returned, to be parsed again with the
+// next one.
+func parseKeys(b []byte) ([]string, []byte) {
+	var keys []string
+	for i := 0; i < len(b); {
+		switch c := b[i]; {
+		case c == 0x1b && i+1 < len(b) && (b[i+1] == '[' || b[i+1] == 'O'):
+			// an escape sequence runs up to its first letter or ~
+			j := i + 2
+			for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+				j++
+			}
+			if j == len(b) {
+				// give up on sequences too long to be keys
+				if len(b)-i > 16 {
+					return keys, nil
+				}
+				return keys, append([]byte{}, b[i:]...)
+			}
+			if k, ok := escapeKeys[string(b[i+2:j+1])]; ok {
+				keys = append(keys, k)
+			}
+			i = j + 1
+		case c == 0x1b:
+			keys = append(keys, "esc")
+			i++
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			i++
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			i++
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			i++
+		case c == 0x0c:
+			keys = append(keys, "ctrl-l")
+			i++
+		case c == 0x0e:
+			keys = append(keys, "down")
+			i++
+		case c == 0x10:
+			keys = append(keys, "up")
+			i++
+		default:
+			r, n := utf8.DecodeRune(b[i:])
+			keys = append(keys, string(r))
+			i += n
+		}
+	}
+	return keys, nil
+}
+
+// escapeKeys names the escape sequences sent by the keys parseKeys
+// knows, without the leading "ESC [".
+var escapeKeys = map[string]string{
+	"A":  "up",
+	"B":  "down",
+	"H":  "home",
+	"F":  "end",
+	"1~": "home",
+	"4~": "end",
+	"5~": "pgup",
+	"6~": "pgdn",
+}
+
+// key handles a key press, returning false to quit.
+func (u *TUI) key(k string) bool {
+	if u.editing {
+		switch k {
+		case "ctrl-c":
+			return false
+		case "enter":
+			u.editing = false
+			u.setFilter(u.input)
+		case "esc":
+			u.editing = false
+		case "backspace":
+			if r := []rune(u.input); len(r) > 0 {
+				u.input = string(r[:len(r)-1])
+			}
+		default:
+			if utf8.RuneCountInString(k) == 1 && k >= " " {
+				u.input += k
+			}
+		}
+		return true
+	}
+
+	u.status = ""
+	_, height := u.Size()
+	switch k {
+	case "q", "ctrl-c":
+		return false
+	case "j", "down":
+		u.selected++
+	case "k", "up":
+		u.selected--
+	case "pgdn", " ":
+		u.selected += height - 4
+	case "pgup", "b":
+		u.selected -= height - 4
+	case "g", "home":
+		u.selected = 0
+	case "G", "end":
+		u.selected = len(u.shown) - 1
+	case "enter", "o":
+		if u.selected < 0 || u.selected >= len(u.shown) {
+			break
+		}
+		if pr := u.shown[u.selected]; pr.URL == "" {
+			u.status = "no link for this pull request"
+		} else if err := u.Open(pr.URL); err != nil {
+			u.status = fmt.Sprintf("can't open %s: %s", pr.URL, err)
+		}
+	case "/":
+		u.editing = true
+		u.input = u.filter
+	case "r":
+		u.fetch()
+	}
+	return true
+}
+
+// setFilter applies a filter typed after a "/", if it's valid.
+func (u *TUI) setFilter(filter string) {
+	q, _ := parseFilter(filter)
+	if _, _, err := withQuery(u.config, q, time.Now()); err != nil {
+		u.status = err.Error()
+		return
+	}
+	u.filter = filter
+	u.selected = 0
+}
+
+// parseFilter splits a filter into the dashboard's query parameters,
+// written like author=me or state=open, and words to search the
+// titles, authors and repos of pull requests for.
+func parseFilter(filter string) (url.Values, []string) {
+	q := url.Values{}
+	var words []string
+	for _, f := range strings.Fields(filter) {
+		if i := strings.Index(f, "="); i > 0 {
+			q.Add(f[:i], f[i+1:])
+		} else {
+			words = append(words, strings.ToLower(f))
+		}
+	}
+	return q, words
+}
+
+// visible returns the pull requests that pass the filter, and the
+// config to draw them with.
+func (u *TUI) visible() (SummarizedPullRequests, Config) {
+	q, words := parseFilter(u.filter)
+	config, stages, err := withQuery(u.config, q, u.now)
+	if err != nil {
+		config, stages = u.config, nil
+	}
+	in := make(chan SummarizedPullRequest)
+	go func() {
+		for _, pr := range u.prs {
+			in <- pr
+		}
+		close(in)
+	}()
+	var filtered <-chan SummarizedPullRequest = in
+	for _, stage := range stages {
+		filtered = stage(filtered)
+	}
+	var prs SummarizedPullRequests
+	for pr := range filtered {
+		text := strings.ToLower(tuiLabel(pr))
+		match := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				match = false
+			}
+		}
+		if match {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, config
+}
+
+// tuiLabel is the text drawn beside a PR's bar.
+func tuiLabel(pr SummarizedPullRequest) string {
+	if pr.Grouped > 0 {
+		return fmt.Sprintf("%s/%s %s", pr.Owner, pr.Repo, pr.Title)
+	}
+	return fmt.Sprintf("%s/%s #%d %s by %s", pr.Owner, pr.Repo, pr.Number, pr.Title, pr.Author)
+}
+
+// draw redraws the whole screen.
+func (u *TUI) draw(out io.Writer) {
+	width, height := u.Size()
+	prs, config := u.visible()
+	config.Customization = config.Customization.WithDefaults()
+	theme := config.Customization
+	grid := ansiColor(theme.GridColor, u.TrueColor)
+	sortPRs(prs, config.Sort, nil)
+	groups := groupPRs(prs, config)
+	axis := newChartAxis(u.now, config)
+
+	// the labels take up to two fifths of the screen, and the chart
+	// the rest
+	labelWidth := width * 2 / 5
+	if labelWidth > 50 {
+		labelWidth = 50
+	}
+	chart := width - labelWidth - 1
+	if chart < 10 {
+		chart = 10
+	}
+
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\x1b[0m\x1b[K\r\n")
+	}
+
+	// title, legend and axis
+	state := fmt.Sprintf("%d of %d pull requests, updated %s", len(prs), len(u.prs), u.now.In(axis.Location).Format("15:04"))
+	if u.now.IsZero() {
+		state = "loading"
+	} else if u.fetching {
+		state += ", refreshing"
+	}
+	line("\x1b[1m%s\x1b[0m %s%s", printable(chartTitle(config)), grid, state)
+	var legend bytes.Buffer
+	tiers := append(theme.tiers(), Tier{Name: "closed", Color: theme.ClosedColor})
+	for i, t := range tiers {
+		name := t.Name
+		if t.Name != "closed" {
+			name += " " + tierRange(tiers[:len(tiers)-1], i)
+		}
+		fmt.Fprintf(&legend, "%s██\x1b[0m %s%s\x1b[0m  ", ansiColor(t.Color, u.TrueColor), grid, name)
+	}
+	line("%s", legend.String())
+	// narrow days are labelled with the day of the week and month, or
+	// just the month, all in the same way
+	labels := axis.Labels()
+	for _, format := range []string{"Mon 2", "2"} {
+		if utf8.RuneCountInString(labels[0]) < chart/10 {
+			break
+		}
+		for i := range labels[:9] {
+			labels[i] = axis.Start.AddDate(0, 0, i).Format(format)
+		}
+	}
+	var days bytes.Buffer
+	for i, label := range labels {
+		n := (i+1)*chart/10 - i*chart/10
+		days.WriteString(fit(label, n-1) + " ")
+	}
+	line("%s%s%s", strings.Repeat(" ", labelWidth+1), grid, days.String())
+
+	// rows, scrolled to keep the selected PR on screen
+	u.shown = nil
+	var rows []string
+	selectedRow := 0
+	if u.selected >= len(prs) {
+		u.selected = len(prs) - 1
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+	for _, g := range groups {
+		if config.GroupBy != "" {
+			open, age := groupSummary(g, config)
+			rows = append(rows, fmt.Sprintf("\x1b[1m%s\x1b[0m %s%d open, median age %s", printable(g.Name), grid, open, age/time.Minute*time.Minute))
+		}
+		for _, pr := range g.PRs {
+			label := fit(tuiLabel(pr), labelWidth)
+			if len(u.shown) == u.selected {
+				label = "\x1b[7m" + label + "\x1b[0m"
+				selectedRow = len(rows)
+			}
+			rows = append(rows, label+" "+u.bar(newChartBar(pr, config, g.Name), axis, chart, grid))
+			u.shown = append(u.shown, pr)
+		}
+	}
+	body := height - 4
+	if body < 1 {
+		body = 1
+	}
+	if selectedRow < u.offset {
+		u.offset = selectedRow
+	}
+	if selectedRow >= u.offset+body {
+		u.offset = selectedRow - body + 1
+	}
+	if u.offset > len(rows)-body {
+		u.offset = len(rows) - body
+	}
+	if u.offset < 0 {
+		u.offset = 0
+	}
+	for i := u.offset; i < u.offset+body; i++ {
+		if i < len(rows) {
+			line("%s", rows[i])
+		} else {
+			line("")
+		}
+	}
+
+	// status line
+	switch {
+	case u.editing:
+		fmt.Fprintf(&b, "/%s█", printable(u.input))
+	case u.status != "":
+		b.WriteString(fit(u.status, width))
+	case len(u.problems) > 0:
+		b.WriteString(fit(fmt.Sprintf("%d problems retrieving pull requests, the first: %s", len(u.problems), u.problems[0]), width))
+	default:
+		fmt.Fprintf(&b, "%sj/k move  enter open  / filter  r refresh  q quit", grid)
+		if u.filter != "" {
+			fmt.Fprintf(&b, "  filter: %s", printable(u.filter))
+		}
+	}
+	b.WriteString("\x1b[0m\x1b[K")
+	out.Write(b.Bytes())
+}
+
+// bar draws a PR's bar across a chart columns wide, shading days off
+// with dots.
+func (u *TUI) bar(bar chartBar, axis chartAxis, columns int, grid string) string {
+	var b bytes.Buffer
+	color := ansiColor(bar.Color, u.TrueColor)
+	span := axis.End.Sub(axis.Start) / time.Duration(columns)
+	current := ""
+	for i := 0; i < columns; i++ {
+		from := axis.Start.Add(time.Duration(i) * span)
+		cell, style := " ", ""
+		for _, p := range axis.DaysOff {
+			if p.from.Before(from.Add(span)) && p.to.After(from) {
+				cell, style = "·", grid
+			}
+		}
+		for _, s := range bar.Segments {
+			if s.from.Before(from.Add(span)) && s.to.After(from) {
+				cell, style = "█", color
+			}
+		}
+		if style != current {
+			b.WriteString("\x1b[0m" + style)
+			current = style
+		}
+		b.WriteString(cell)
+	}
+	return b.String()
+}
+
+// ansiColor returns the escape code that draws text in a CSS hex
+// color, either exactly or as the nearest color in the 6x6x6 cube of
+// the 256 color palette. Other CSS colors are drawn in the terminal's
+// own color.
+func ansiColor(css string, trueColor bool) string {
+	rgb, ok := parseHexColor(css)
+	if !ok {
+		return ""
+	}
+	if trueColor {
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", int(rgb[0]), int(rgb[1]), int(rgb[2]))
+	}
+	cube := func(v float64) int {
+		return int(v/255*5 + 0.5)
+	}
+	return fmt.Sprintf("\x1b[38;5;%dm", 16+36*cube(rgb[0])+6*cube(rgb[1])+cube(rgb[2]))
+}
+
+// fit pads or cuts s to exactly n characters, so long titles and
+// messages don't wrap onto the next line. Control characters are
+// replaced, as with printable.
+func fit(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	s = printable(s)
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s + strings.Repeat(" ", n-len(r))
+}
+
+// printable replaces the control characters in s with "?", so that
+// text from github, like PR titles and error messages, can't send
+// escape sequences to the terminal.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return '?'
+		}
+		return r
+	}, s)
+}
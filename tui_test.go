@@ -0,0 +1,164 @@
+package prmonitor
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	for _, c := range []struct {
+		in       string
+		expected []string
+		rest     string
+	}{
+		{"j", []string{"j"}, ""},
+		{"\x1b[A\x1b[B", []string{"up", "down"}, ""},
+		{"\x1b[6~", []string{"pgdn"}, ""},
+		{"\x1b", []string{"esc"}, ""},
+		{"\r\x7f\x03", []string{"enter", "backspace", "ctrl-c"}, ""},
+		{"\x1b[Z", nil, ""},
+		{"é", []string{"é"}, ""},
+		// sequences cut off by the end of a read are kept for the next
+		{"\x1b[", nil, "\x1b["},
+		{"j\x1bO", []string{"j"}, "\x1bO"},
+		{"\x1b[6", nil, "\x1b[6"},
+	} {
+		keys, rest := parseKeys([]byte(c.in))
+		if !reflect.DeepEqual(keys, c.expected) || string(rest) != c.rest {
+			t.Logf("ERROR: expected %q to be %q and %q, but got %q and %q", c.in, c.expected, c.rest, keys, rest)
+			t.Fail()
+		}
+	}
+	if keys, _ := parseKeys(append([]byte("\x1b[6"), "~"...)); !reflect.DeepEqual(keys, []string{"pgdn"}) {
+		t.Logf("ERROR: expected a sequence split across reads to be pgdn, but got %q", keys)
+		t.Fail()
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	q, words := parseFilter("author=LK4D4  Fix state=open")
+	if q.Get("author") != "LK4D4" || q.Get("state") != "open" || !reflect.DeepEqual(words, []string{"fix"}) {
+		t.Logf("ERROR: unexpected filter %v %q", q, words)
+		t.Fail()
+	}
+}
+
+func TestANSIColor(t *testing.T) {
+	for _, c := range []struct {
+		css       string
+		trueColor bool
+		expected  string
+	}{
+		{"#cc0000", true, "\x1b[38;2;204;0;0m"},
+		{"#cc0000", false, "\x1b[38;5;160m"},
+		{"#fff", false, "\x1b[38;5;231m"},
+		{"red", false, ""},
+	} {
+		if color := ansiColor(c.css, c.trueColor); color != c.expected {
+			t.Logf("ERROR: expected %s to be %q, but got %q", c.css, c.expected, color)
+			t.Fail()
+		}
+	}
+}
+
+func TestTUI(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	var opened string
+	u := NewTUI(Config{Customization: GetCustomizations()}, nil)
+	u.Size = func() (int, int) { return 100, 10 }
+	u.Open = func(url string) error {
+		opened = url
+		return nil
+	}
+	u.TrueColor = true
+	u.now = now
+	u.prs = SummarizedPullRequests{
+		{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "old pr", Author: "LK4D4", URL: "https://github.com/docker/swarmkit/pull/1",
+			OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"},
+		{Owner: "docker", Repo: "docker", Number: 2, Title: "new pr", Author: "aaronl", URL: "https://github.com/docker/docker/pull/2",
+			OpenedAt: now.Add(-2 * time.Hour), ClosedAt: now, State: "open"},
+	}
+
+	var w bytes.Buffer
+	u.draw(&w)
+	for _, e := range []string{
+		"Recent Pull Requests",
+		"2 of 2 pull requests, updated 12:00",
+		"\x1b[7mdocker/docker #2 new pr by aaronl",
+		"24   25    26",
+		"\x1b[38;2;204;0;0m█",
+	} {
+		if !strings.Contains(w.String(), e) {
+			t.Logf("ERROR: expected %q in %q", e, w.String())
+			t.Fail()
+		}
+	}
+
+	// move down, then filter down to that PR and open it
+	keys, _ := parseKeys([]byte("j/new\r\r"))
+	for _, k := range keys {
+		if !u.key(k) {
+			t.Logf("ERROR: expected %q not to quit", k)
+			t.Fail()
+		}
+		u.draw(&w)
+	}
+	if u.filter != "new" || len(u.shown) != 1 || opened != "https://github.com/docker/docker/pull/2" {
+		t.Logf("ERROR: expected the filtered PR to be opened, but got %q %d %q", u.filter, len(u.shown), opened)
+		t.Fail()
+	}
+
+	// invalid filters are rejected
+	keys, _ = parseKeys([]byte("/\x7f\x7f\x7fstate=merged\r"))
+	for _, k := range keys {
+		u.key(k)
+	}
+	if u.filter != "new" || !strings.Contains(u.status, "unknown state") {
+		t.Logf("ERROR: expected the filter to be rejected, but got %q %q", u.filter, u.status)
+		t.Fail()
+	}
+	if u.key("q") {
+		t.Logf("ERROR: expected q to quit")
+		t.Fail()
+	}
+}
+
+func TestTUIControlCharacters(t *testing.T) {
+	now := time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+	u := NewTUI(Config{Customization: GetCustomizations(), GroupBy: "author"}, nil)
+	u.Size = func() (int, int) { return 160, 10 }
+	u.now = now
+	u.prs = SummarizedPullRequests{
+		{Owner: "docker", Repo: "swarmkit", Number: 1, Title: "evil \x1b]0;pwned\x07 \u009b2J", Author: "bad\x1b[2Jguy",
+			OpenedAt: now.Add(-72 * time.Hour), ClosedAt: now, State: "open"},
+	}
+	u.problems = []string{"server said \x1b[31mno"}
+	var w bytes.Buffer
+	u.draw(&w)
+	for _, e := range []string{"\x1b]", "\x07", "\u009b", "\x1b[2J", "\x1b[31m"} {
+		if strings.Contains(w.String(), e) {
+			t.Logf("ERROR: expected %q to be replaced in %q", e, w.String())
+			t.Fail()
+		}
+	}
+	if !strings.Contains(w.String(), "evil ?]0;pwned? ?2J") {
+		t.Logf("ERROR: expected the title with its control characters replaced in %q", w.String())
+		t.Fail()
+	}
+}
+
+func TestTUIRun(t *testing.T) {
+	u := NewTUI(Config{}, nil)
+	var w bytes.Buffer
+	if err := u.Run(strings.NewReader("q"), &w); err != nil {
+		t.Logf("ERROR: expected q to quit cleanly, but got %s", err)
+		t.Fail()
+	}
+	if !strings.HasPrefix(w.String(), "\x1b[?1049h") || !strings.HasSuffix(w.String(), "\x1b[?1049l") {
+		t.Logf("ERROR: expected the alternate screen to be used and left, but got %q", w.String())
+		t.Fail()
+	}
+}